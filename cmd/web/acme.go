@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeCacheStore is the subset of data.ACMECacheModel used by dbCertCache.
+type acmeCacheStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+	Delete(key string) error
+}
+
+// dbCertCache implements autocert.Cache on top of the acme_cache table, so certificates survive restarts and deploys.
+type dbCertCache struct {
+	store acmeCacheStore
+}
+
+func (c dbCertCache) Get(_ context.Context, key string) ([]byte, error) {
+	b, err := c.store.Get(key)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return nil, autocert.ErrCacheMiss
+	}
+	return b, err
+}
+
+func (c dbCertCache) Put(_ context.Context, key string, b []byte) error {
+	return c.store.Put(key, b)
+}
+
+func (c dbCertCache) Delete(_ context.Context, key string) error {
+	return c.store.Delete(key)
+}
+
+// newCertManager returns an autocert.Manager that obtains and renews certificates for the -acme-domains.
+// Certificates are cached in -acme-cache-dir if it's set, otherwise in the database.
+func newCertManager(cfg config, store acmeCacheStore) (*autocert.Manager, error) {
+	var cache autocert.Cache = dbCertCache{store: store}
+	if cfg.ACME.CacheDir != "" {
+		cache = autocert.DirCache(cfg.ACME.CacheDir)
+	}
+
+	client := &acme.Client{DirectoryURL: cfg.ACME.Directory}
+
+	// A local ACME server, such as Pebble, serves its directory over HTTPS using a certificate from its own CA.
+	// So the client needs to trust that CA, on top of the system ones.
+	if cfg.ACME.CACert != "" {
+		pem, err := os.ReadFile(cfg.ACME.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ACME.CACert)
+		}
+
+		client.HTTPClient = &http.Client{
+			Timeout: time.Minute,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			},
+		}
+	}
+
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       cache,
+		HostPolicy:  autocert.HostWhitelist(cfg.ACME.Domains...),
+		RenewBefore: cfg.ACME.RenewBefore,
+		Email:       cfg.ACME.Email,
+		Client:      client,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// testACMECacheStore is an in memory acmeCacheStore.
+type testACMECacheStore map[string][]byte
+
+func (s testACMECacheStore) Get(key string) ([]byte, error) {
+	b, ok := s[key]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	return b, nil
+}
+
+func (s testACMECacheStore) Put(key string, b []byte) error {
+	s[key] = b
+	return nil
+}
+
+func (s testACMECacheStore) Delete(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestDBCertCache(t *testing.T) {
+	ctx := context.Background()
+	cache := dbCertCache{store: testACMECacheStore{}}
+
+	_, err := cache.Get(ctx, "example.com")
+	assert.Equal(t, errors.Is(err, autocert.ErrCacheMiss), true)
+
+	assert.NilError(t, cache.Put(ctx, "example.com", []byte("cert")))
+	b, err := cache.Get(ctx, "example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "cert")
+
+	assert.NilError(t, cache.Delete(ctx, "example.com"))
+	_, err = cache.Get(ctx, "example.com")
+	assert.Equal(t, errors.Is(err, autocert.ErrCacheMiss), true)
+}
+
+func TestNewCertManager(t *testing.T) {
+	caCert, _ := writeTestCert(t, t.TempDir(), "pebble")
+
+	var cfg config
+	cfg.ACME.Domains = []string{"example.com"}
+	cfg.ACME.Directory = "https://localhost:14000/dir"
+	cfg.ACME.CACert = caCert
+
+	m, err := newCertManager(cfg, testACMECacheStore{})
+	assert.NilError(t, err)
+	assert.Equal(t, m.Client.DirectoryURL, "https://localhost:14000/dir")
+	assert.Equal(t, m.Client.HTTPClient != nil, true)
+
+	_, ok := m.Cache.(dbCertCache)
+	assert.Equal(t, ok, true)
+
+	// Only the configured domains should be allowed.
+	assert.NilError(t, m.HostPolicy(context.Background(), "example.com"))
+	assert.Equal(t, m.HostPolicy(context.Background(), "evil.example.com") != nil, true)
+
+	// A cache directory replaces the database.
+	cfg.ACME.CacheDir = t.TempDir()
+	m, err = newCertManager(cfg, testACMECacheStore{})
+	assert.NilError(t, err)
+	_, ok = m.Cache.(autocert.DirCache)
+	assert.Equal(t, ok, true)
+
+	cfg.ACME.CACert = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = newCertManager(cfg, testACMECacheStore{})
+	assert.Equal(t, err != nil, true)
+}
+
+func TestACMEChallengeHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	var cfg config
+	cfg.ACME.Domains = []string{"example.com"}
+	m, err := newCertManager(cfg, testACMECacheStore{})
+	assert.NilError(t, err)
+	app.certManager = m
+
+	// Challenges are answered by the manager rather than redirected, and unknown tokens don't exist.
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/acme-challenge/unknown", nil)
+	r.Host = "example.com"
+	rr := httptest.NewRecorder()
+	app.redirectRoutes().ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusNotFound)
+	assert.Equal(t, rr.Header().Get("Location"), "")
+}
+
+// TestACMEPebble obtains and renews a certificate from Pebble, so it's skipped unless PEBBLE_DIRECTORY is set.
+// Start Pebble with "docker compose up pebble", then run:
+//
+//	PEBBLE_DIRECTORY=https://localhost:14000/dir PEBBLE_CA_CERT=pebble.minica.pem go test ./cmd/web -run Pebble
+func TestACMEPebble(t *testing.T) {
+	directory := os.Getenv("PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("PEBBLE_DIRECTORY isn't set")
+	}
+
+	var cfg config
+	cfg.ACME.Domains = []string{"site.test"}
+	cfg.ACME.Directory = directory
+	cfg.ACME.CACert = os.Getenv("PEBBLE_CA_CERT")
+	store := testACMECacheStore{}
+
+	// Pebble is run with PEBBLE_VA_ALWAYS_VALID, so there's no need to answer the challenges.
+	hello := &tls.ClientHelloInfo{ServerName: "site.test"}
+	serial := func(m *autocert.Manager) string {
+		t.Helper()
+		cert, err := m.GetCertificate(hello)
+		assert.NilError(t, err)
+		return cert.Leaf.SerialNumber.String()
+	}
+
+	m, err := newCertManager(cfg, store)
+	assert.NilError(t, err)
+	issued := serial(m)
+
+	// A manager sharing the cache loads the same certificate, rather than obtaining another one.
+	m, err = newCertManager(cfg, store)
+	assert.NilError(t, err)
+	assert.Equal(t, serial(m), issued)
+
+	// Renewing longer before expiry than the certificate is valid for forces a renewal in the background.
+	cfg.ACME.RenewBefore = 100 * 365 * 24 * time.Hour
+	m, err = newCertManager(cfg, store)
+	assert.NilError(t, err)
+	assert.Equal(t, serial(m), issued)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for serial(m) == issued {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate wasn't renewed")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
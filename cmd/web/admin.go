@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/env"
+)
+
+// adminEnabled reports whether the admin listener should be started.
+// It needs an address to listen on, and some credentials to protect it with.
+func (app *application) adminEnabled() bool {
+	admin := app.config.Admin
+	return admin.Addr != "" && (admin.Token != "" || (admin.Username != "" && admin.Password != ""))
+}
+
+// adminRoutes handles the operational endpoints, which are served on the -admin-addr listener rather than the public one.
+// Every route requires the admin credentials.
+func (app *application) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /metrics", app.metrics.handler())
+	mux.Handle("GET /readyz", app.health.ReportHandler())
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /debug/config", app.configHandler)
+	mux.HandleFunc("GET /debug/log-level", app.logLevelHandler)
+	mux.HandleFunc("PUT /debug/log-level", app.logLevelHandler)
+
+	// pprof.Index serves the named profiles (heap, goroutine, etc.) as well as the index page.
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+
+	return app.requestID(app.requireAdmin(mux))
+}
+
+// configHandler shows the running configuration, with secrets redacted, in the same format as -print-config.
+func (app *application) configHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	err := env.Dump(w, app.config)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// requireAdmin only lets requests with the admin credentials through.
+// These can be -admin-token sent as a bearer token (i.e. by Prometheus), or -admin-username and -admin-password
+// sent using basic auth (i.e. by a browser). Constant time comparisons are used, so they can't be guessed using a timing attack.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="admin"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdmin checks the request's credentials against the ones that have been configured.
+// Empty credentials never match, so a method that hasn't been configured can't be used.
+func (app *application) isAdmin(r *http.Request) bool {
+	admin := app.config.Admin
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return admin.Token != "" && secureCompare(token, admin.Token)
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		if admin.Username == "" || admin.Password == "" {
+			return false
+		}
+		// Both are always compared, so the time taken doesn't reveal whether the username was right.
+		usernameMatch := secureCompare(username, admin.Username)
+		passwordMatch := secureCompare(password, admin.Password)
+		return usernameMatch && passwordMatch
+	}
+
+	return false
+}
+
+// secureCompare compares a and b in constant time.
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		username string
+		password string
+		setAuth  func(r *http.Request)
+		wantCode int
+	}{
+		{
+			name:     "No credentials sent",
+			token:    "secret",
+			setAuth:  func(r *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Valid token",
+			token:    "secret",
+			setAuth:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Wrong token",
+			token:    "secret",
+			setAuth:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Empty token when none is configured",
+			username: "admin",
+			password: "hunter2",
+			setAuth:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Valid basic auth",
+			username: "admin",
+			password: "hunter2",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Wrong password",
+			username: "admin",
+			password: "hunter2",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("admin", "guess") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Basic auth when only a token is configured",
+			token:    "secret",
+			setAuth:  func(r *http.Request) { r.SetBasicAuth("", "") },
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.Admin.Token = tt.token
+			app.config.Admin.Username = tt.username
+			app.config.Admin.Password = tt.password
+
+			r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			tt.setAuth(r)
+			rr := httptest.NewRecorder()
+			app.adminRoutes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.Admin.Token = "secret"
+	app.config.SMTP.Password = "hunter2"
+	app.health.Add("database", 0, func(ctx context.Context) error { return nil })
+
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{path: "/metrics", wantBody: "# TYPE http_requests_in_flight gauge"},
+		{path: "/readyz", wantBody: `"checks":{"database":{"status":"ok"`},
+		{path: "/debug/vars", wantBody: `"memstats"`},
+		{path: "/debug/config", wantBody: `smtp_password = "[redacted]"`},
+		{path: "/debug/log-level", wantBody: "INFO"},
+		{path: "/debug/pprof/", wantBody: "goroutine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			app.adminRoutes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.StringContains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestAdminRoutesNotPublic(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.Admin.Token = "secret"
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	for _, path := range []string{"/metrics", "/debug/vars", "/debug/pprof/", "/debug/log-level"} {
+		code, _, _ := ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+}
+
+func TestAdminEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		token    string
+		username string
+		password string
+		want     bool
+	}{
+		{name: "Token", addr: "localhost:4001", token: "secret", want: true},
+		{name: "Basic auth", addr: "localhost:4001", username: "admin", password: "hunter2", want: true},
+		{name: "No credentials", addr: "localhost:4001", want: false},
+		{name: "Username only", addr: "localhost:4001", username: "admin", want: false},
+		{name: "No address", token: "secret", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.Admin.Addr = tt.addr
+			app.config.Admin.Token = tt.token
+			app.config.Admin.Username = tt.username
+			app.config.Admin.Password = tt.password
+
+			assert.Equal(t, app.adminEnabled(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+// contextKey is a custom type used for request context keys, so they can't collide with keys set by other packages.
+type contextKey string
+
+const (
+	authenticatedUserContextKey = contextKey("authenticatedUser")
+	clientIPContextKey          = contextKey("clientIP")
+	isAuthenticatedContextKey   = contextKey("isAuthenticated")
+	routePatternContextKey      = contextKey("routePattern")
+)
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/devcert"
+)
+
+// ensureDevcert makes sure the development certificate exists in dir, or the default directory if dir is empty.
+func ensureDevcert(dir string) (devcert.Files, bool, error) {
+	if dir == "" {
+		var err error
+		dir, err = devcert.DefaultDir()
+		if err != nil {
+			return devcert.Files{}, false, err
+		}
+	}
+	return devcert.Ensure(dir)
+}
+
+// runDevcert handles the "devcert" subcommand, generating the development certificate and explaining how to trust it.
+func runDevcert(dir string) error {
+	files, generated, err := ensureDevcert(dir)
+	if err != nil {
+		return err
+	}
+
+	if generated {
+		fmt.Println("Generated a development certificate for localhost.")
+	} else {
+		fmt.Println("The development certificate for localhost is up to date.")
+	}
+	fmt.Printf("\nCA certificate:    %s\n", files.CACert)
+	fmt.Printf("Certificate:       %s\n", files.Cert)
+	fmt.Printf("Key:               %s\n", files.Key)
+	fmt.Println("\nTo stop browsers warning about the certificate, add the CA certificate to your system (or browser) trust store.")
+	fmt.Println("The site uses it with -tls-dev-cert when -env=development, unless -tls-cert or -acme-domains are set.")
+	return nil
+}
+
+// useDevcert reports whether the development certificate should be used, which is when it's been asked for with -tls-dev-cert
+// in development, and no other certificate is configured. This way secure session cookies work the same as they do in production.
+func useDevcert(conf config) bool {
+	return conf.TLS.DevCert && conf.Env == "development" && conf.TLS.Cert == "" && len(conf.ACME.Domains) == 0
+}
@@ -14,5 +14,11 @@ func (app *application) logError(r *http.Request, err error) {
 		uri    = r.URL.RequestURI()
 	)
 
-	app.logger.Error(err.Error(), slog.String("method", method), slog.String("uri", uri), slog.String("trace", trace))
+	app.logger.ErrorContext(r.Context(), err.Error(), slog.String("method", method), slog.String("uri", uri), slog.String("trace", trace))
+}
+
+// clientError sends a specific status code and corresponding description to the user.
+// This is used for things like 400 "Bad Request" when there's a problem with the request the user sent.
+func (app *application) clientError(w http.ResponseWriter, status int) {
+	http.Error(w, http.StatusText(status), status)
 }
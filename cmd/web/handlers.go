@@ -1,10 +1,50 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"runtime/debug"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/validator"
 )
 
+// userLoginForm holds the values and validation errors for the login form.
+type userLoginForm struct {
+	Email    string
+	Password string
+	validator.Validator
+}
+
+// userPasswordForgotForm holds the values and validation errors for the forgot password form.
+type userPasswordForgotForm struct {
+	Email string
+	validator.Validator
+}
+
+// userPasswordResetForm holds the values and validation errors for the reset password form.
+type userPasswordResetForm struct {
+	Token           string
+	Password        string
+	ConfirmPassword string
+	validator.Validator
+}
+
+// userSignupForm holds the values and validation errors for the signup form.
+type userSignupForm struct {
+	Name     string
+	Email    string
+	Password string
+	validator.Validator
+}
+
+// userVerifyForm holds the token for the verify email form.
+type userVerifyForm struct {
+	Token string
+	validator.Validator
+}
+
 // aboutHandler displays the about page.
 func (app *application) aboutHandler(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
@@ -15,6 +55,16 @@ func (app *application) aboutHandler(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
+// forbiddenHandler displays a 403 page, such as when a form is submitted without a valid CSRF token.
+func (app *application) forbiddenHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "403 page"
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Forbidden"
+	app.render(w, r, http.StatusForbidden, "403.tmpl", data)
+}
+
 // homeHandler displays the home page.
 func (app *application) homeHandler(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
@@ -49,3 +99,469 @@ func (app *application) serverErrorHandler(w http.ResponseWriter, r *http.Reques
 	data.Title = "Server Error"
 	app.render(w, r, http.StatusInternalServerError, "500.tmpl", data)
 }
+
+// tooManyRequestsHandler displays a 429 page, for when a client has been rate limited.
+func (app *application) tooManyRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "429 page"
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Too Many Requests"
+	app.render(w, r, http.StatusTooManyRequests, "429.tmpl", data)
+}
+
+// unverifiedHandler displays a page asking the user to verify their email address before continuing.
+func (app *application) unverifiedHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "Verify your email address"
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Verify Your Email"
+	app.render(w, r, http.StatusForbidden, "unverified.tmpl", data)
+}
+
+// userAccountHandler displays the user's account details.
+func (app *application) userAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.authenticatedUser(r)
+	if !ok {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Description = "Your account"
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Account"
+	data.User = user
+	app.render(w, r, http.StatusOK, "account.tmpl", data)
+}
+
+// userLoginHandler displays the login form.
+func (app *application) userLoginHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "Log in to your account"
+	data.Form = userLoginForm{}
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Login"
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
+}
+
+// userLoginPostHandler authenticates the user and stores their ID in the session.
+func (app *application) userLoginPostHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userLoginForm{
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() {
+		var id int
+		id, err = app.models.Users.Authenticate(form.Email, form.Password)
+		if err != nil {
+			if !errors.Is(err, data.ErrInvalidCredentials) {
+				app.serverErrorHandler(w, r, err)
+				return
+			}
+			form.AddNonFieldError("Email or password is incorrect")
+		} else {
+			// Renew the session token whenever the authentication state changes to prevent session fixation attacks.
+			err = app.sessionManager.RenewToken(r.Context())
+			if err != nil {
+				app.serverErrorHandler(w, r, err)
+				return
+			}
+
+			app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+	}
+
+	// Don't send the password back to the browser when re-displaying the form.
+	form.Password = ""
+
+	data := app.newTemplateData(r)
+	data.Description = "Log in to your account"
+	data.Form = form
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Login"
+	app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+}
+
+// userLogoutPostHandler removes the authenticated user ID from the session.
+func (app *application) userLogoutPostHandler(w http.ResponseWriter, r *http.Request) {
+	// Renew the session token whenever the authentication state changes.
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// userPasswordForgotHandler displays the forgot password form.
+func (app *application) userPasswordForgotHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "Reset your password"
+	data.Form = userPasswordForgotForm{}
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Forgot Password"
+	app.render(w, r, http.StatusOK, "password-forgot.tmpl", data)
+}
+
+// userPasswordForgotPostHandler emails a password reset link to the user, if an account exists for the email address.
+func (app *application) userPasswordForgotPostHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userPasswordForgotForm{
+		Email: r.PostForm.Get("email"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Description = "Reset your password"
+		data.Form = form
+		data.ImageUrl = "/static/images/default_og_image.png"
+		data.PageType = "website"
+		data.Title = "Forgot Password"
+		app.render(w, r, http.StatusUnprocessableEntity, "password-forgot.tmpl", data)
+		return
+	}
+
+	// Looking up the user and sending the email happens in a background job.
+	// This way the response takes the same amount of time whether an account exists or not, so it can't be used to find out
+	// which email addresses have accounts.
+	payload, err := newSendPasswordResetEmailPayload(form.Email)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+	err = app.jobs.Enqueue(jobSendPasswordResetEmail, payload)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a link to reset your password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// userPasswordResetHandler displays the reset password form, as long as the token in the URL is valid.
+func (app *application) userPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	form := userPasswordResetForm{
+		Token: r.URL.Query().Get("token"),
+	}
+
+	if !validPlaintextToken(form.Token) {
+		app.invalidPasswordResetToken(w, r)
+		return
+	}
+
+	_, err := app.models.Users.GetForToken(data.ScopePasswordReset, form.Token)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.invalidPasswordResetToken(w, r)
+			return
+		}
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Description = "Choose a new password"
+	data.Form = form
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Reset Password"
+	app.render(w, r, http.StatusOK, "password-reset.tmpl", data)
+}
+
+// userPasswordResetPostHandler consumes the password reset token, updates the user's password,
+// and logs the user out everywhere.
+func (app *application) userPasswordResetPostHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userPasswordResetForm{
+		Token:           r.PostForm.Get("token"),
+		Password:        r.PostForm.Get("password"),
+		ConfirmPassword: r.PostForm.Get("confirm_password"),
+	}
+
+	if !validPlaintextToken(form.Token) {
+		app.invalidPasswordResetToken(w, r)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.Password, 72), "password", "This field cannot be more than 72 bytes long")
+	form.CheckField(form.Password == form.ConfirmPassword, "confirm_password", "Passwords do not match")
+
+	if !form.Valid() {
+		form.Password = ""
+		form.ConfirmPassword = ""
+
+		data := app.newTemplateData(r)
+		data.Description = "Choose a new password"
+		data.Form = form
+		data.ImageUrl = "/static/images/default_og_image.png"
+		data.PageType = "website"
+		data.Title = "Reset Password"
+		app.render(w, r, http.StatusUnprocessableEntity, "password-reset.tmpl", data)
+		return
+	}
+
+	// Resetting the password consumes the token, so it can't be used a second time, and deletes the user's other tokens.
+	userID, err := app.models.Users.ResetPassword(form.Token, form.Password)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.invalidPasswordResetToken(w, r)
+			return
+		}
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	// Delete any other sessions the user has, in case someone else had access to their account.
+	err = app.destroyUserSessions(userID)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	// The current session is saved at the end of the request, so log it out and renew it as well.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset! Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// invalidPasswordResetToken redirects the user back to the forgot password form with a flash message explaining why.
+func (app *application) invalidPasswordResetToken(w http.ResponseWriter, r *http.Request) {
+	app.sessionManager.Put(r.Context(), "flash", "That password reset link is invalid or has expired. Please request a new one.")
+	http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+}
+
+// userSignupHandler displays the signup form.
+func (app *application) userSignupHandler(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Description = "Create a new account"
+	data.Form = userSignupForm{}
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Signup"
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
+}
+
+// userSignupPostHandler validates the signup form and creates a new user.
+func (app *application) userSignupPostHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := userSignupForm{
+		Name:     r.PostForm.Get("name"),
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	// bcrypt only uses the first 72 bytes of a password, so reject anything longer.
+	form.CheckField(validator.MaxBytes(form.Password, 72), "password", "This field cannot be more than 72 bytes long")
+
+	if form.Valid() {
+		var id int
+		id, err = app.models.Users.Insert(form.Name, form.Email, form.Password)
+		if err != nil {
+			if !errors.Is(err, data.ErrDuplicateEmail) {
+				app.serverErrorHandler(w, r, err)
+				return
+			}
+			form.AddFieldError("email", "Email address is already in use")
+		} else {
+			// Queue the welcome email, containing the link to verify their email address, so the user doesn't have to wait for it.
+			// The account has already been created, so if this fails, log it and let the user request another email later.
+			payload, err := newSendVerificationEmailPayload(id, "welcome.tmpl")
+			if err == nil {
+				err = app.jobs.Enqueue(jobSendVerificationEmail, payload)
+			}
+			if err != nil {
+				app.logError(r, err)
+			}
+
+			app.sessionManager.Put(r.Context(), "flash", "Your signup was successful! Check your email to verify your account, then log in.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+	}
+
+	// Don't send the password back to the browser when re-displaying the form.
+	form.Password = ""
+
+	data := app.newTemplateData(r)
+	data.Description = "Create a new account"
+	data.Form = form
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Signup"
+	app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+}
+
+// userVerifyHandler asks the user to confirm verifying their email address, as long as the token in the URL is valid.
+// The token is only consumed once the form is submitted, so email scanners and link previews that follow the link don't use it up.
+func (app *application) userVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	form := userVerifyForm{
+		Token: r.URL.Query().Get("token"),
+	}
+
+	if !validPlaintextToken(form.Token) {
+		app.invalidVerificationToken(w, r)
+		return
+	}
+
+	_, err := app.models.Users.GetForToken(data.ScopeVerification, form.Token)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.invalidVerificationToken(w, r)
+			return
+		}
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Description = "Verify your email address"
+	data.Form = form
+	data.ImageUrl = "/static/images/default_og_image.png"
+	data.PageType = "website"
+	data.Title = "Verify Email"
+	app.render(w, r, http.StatusOK, "verify.tmpl", data)
+}
+
+// userVerifyPostHandler consumes the verification token and marks the user's email address as verified.
+func (app *application) userVerifyPostHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if !validPlaintextToken(token) {
+		app.invalidVerificationToken(w, r)
+		return
+	}
+
+	// Consuming the token deletes it, so it can't be used a second time.
+	userID, err := app.models.Tokens.Consume(data.ScopeVerification, token)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.invalidVerificationToken(w, r)
+			return
+		}
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Verify(userID)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	// Any other verification tokens for the user are no longer needed.
+	err = app.models.Tokens.DeleteScopeForUser(data.ScopeVerification, userID)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// userVerifyResendPostHandler sends the authenticated user a new verification email.
+// Users can only request a new email once every few minutes.
+func (app *application) userVerifyResendPostHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.authenticatedUser(r)
+	if !ok {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	if user.IsVerified() {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified!")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	err := app.models.Users.MarkVerificationSent(user.ID, 5*time.Minute)
+	if err != nil {
+		if errors.Is(err, data.ErrVerificationThrottled) {
+			app.sessionManager.Put(r.Context(), "flash", "We've sent you a verification email recently. Please wait a few minutes before asking for another one.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	payload, err := newSendVerificationEmailPayload(user.ID, "verify-email.tmpl")
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+	err = app.jobs.Enqueue(jobSendVerificationEmail, payload)
+	if err != nil {
+		app.serverErrorHandler(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "We've sent you a new verification email.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// invalidVerificationToken redirects the user home with a flash message explaining the verification link didn't work.
+func (app *application) invalidVerificationToken(w http.ResponseWriter, r *http.Request) {
+	app.sessionManager.Put(r.Context(), "flash", "That verification link is invalid or has expired. Log in to request a new one.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/internal/jobs"
 )
 
 func TestHomeHandler(t *testing.T) {
@@ -52,11 +56,222 @@ func TestServerErrorHandler(t *testing.T) {
 	defer ts.Close()
 
 	// Make a request to the handler being tested.
-	code, _, body := ts.get(t, "/server-error")
+	code, header, body := ts.get(t, "/server-error")
 
 	// Assert we're getting a 200 code response.
 	assert.Equal(t, code, http.StatusInternalServerError)
 
 	// Assert that the body contains the text from the <title> tag.
 	assert.StringContains(t, body, "<title>Server Error - Site</title>")
+
+	// Assert that the request ID is shown, so it can be quoted in bug reports.
+	assert.StringContains(t, body, "<code>"+header.Get("X-Request-ID")+"</code>")
+}
+
+func TestUserLoginHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/login")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<title>Login - Site</title>")
+	assert.StringContains(t, body, `<form action="/user/login" method="POST" novalidate>`)
+}
+
+func TestUserSignupHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/signup")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<title>Signup - Site</title>")
+	assert.StringContains(t, body, `<form action="/user/signup" method="POST" novalidate>`)
+}
+
+func TestUserSignupPostHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// Make a GET request to get a valid CSRF token for the session.
+	_, _, body := ts.get(t, "/user/signup")
+	csrfToken := extractCSRFToken(t, body)
+
+	// Note: only invalid submissions are tested here, since valid ones need a database.
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		wantCode     int
+		wantError    string
+	}{
+		{
+			name:         "Empty name",
+			userName:     "",
+			userEmail:    "bob@example.com",
+			userPassword: "validPa$$word",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantError:    "This field cannot be blank",
+		},
+		{
+			name:         "Invalid email",
+			userName:     "Bob",
+			userEmail:    "bob@example.",
+			userPassword: "validPa$$word",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantError:    "This field must be a valid email address",
+		},
+		{
+			name:         "Short password",
+			userName:     "Bob",
+			userEmail:    "bob@example.com",
+			userPassword: "pa$$",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantError:    "This field must be at least 8 characters long",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantError)
+		})
+	}
+}
+
+func TestUserLogoutPostHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// Make a GET request to get a valid CSRF token for the session.
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// Logging out without being logged in should redirect to the login page.
+	code, headers, _ := ts.postForm(t, "/user/logout", form)
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
+
+func TestUserPasswordForgotHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/password/forgot")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<title>Forgot Password - Site</title>")
+
+	// Submitting an invalid email address should re-display the form.
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("email", "not an email")
+
+	code, _, body = ts.postForm(t, "/user/password/forgot", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be a valid email address")
+}
+
+func TestUserPasswordForgotPostHandler(t *testing.T) {
+	app := newTestApplication(t)
+	store := &testJobStore{}
+	app.jobs = jobs.New(store, app.logger, jobs.Config{MaxAttempts: 3})
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("email", "bob@example.com")
+
+	code, headers, _ := ts.postForm(t, "/user/password/forgot", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// The email should be sent by a background job, rather than during the request.
+	enqueued := store.enqueued()
+	assert.Equal(t, len(enqueued), 1)
+	assert.Equal(t, enqueued[0].Name, jobSendPasswordResetEmail)
+	assert.Equal(t, enqueued[0].MaxAttempts, 3)
+
+	// The token is generated up front, so retries of the job email the same link.
+	var payload sendPasswordResetEmailPayload
+	err := json.Unmarshal(enqueued[0].Payload, &payload)
+	assert.NilError(t, err)
+	assert.Equal(t, payload.Email, "bob@example.com")
+	assert.Equal(t, len(payload.Token), 26)
+	assert.Equal(t, payload.TokenExpiry.After(time.Now().Add(passwordResetTokenTTL-time.Minute)), true)
+}
+
+func TestUserPasswordResetHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		urlPath string
+	}{
+		{
+			name:    "Missing token",
+			urlPath: "/user/password/reset",
+		},
+		{
+			name:    "Malformed token",
+			urlPath: "/user/password/reset?token=tooShort",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/user/password/forgot")
+		})
+	}
+}
+
+func TestUserVerifyHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// A malformed token should be rejected without needing the database.
+	code, headers, _ := ts.get(t, "/user/verify?token=tooShort")
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
+}
+
+func TestUserVerifyPostHandler(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	// A malformed token should be rejected without needing the database.
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("token", "tooShort")
+	code, headers, _ := ts.postForm(t, "/user/verify", form)
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	fail := false
+	app.health.Add("database", 0, func(ctx context.Context) error {
+		if fail {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `{"status":"ok"}`)
+
+	// Health checks don't go through the site's middleware, so they don't start sessions or get rate limited.
+	assert.Equal(t, header.Get("Set-Cookie"), "")
+	assert.Equal(t, header.Get("RateLimit-Limit"), "")
+
+	fail = true
+	// The public endpoint doesn't reveal why the site isn't ready, that's only served on the admin listener.
+	code, _, body = ts.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, body, `{"status":"fail"}`)
+
+	// Once shutdown begins, the site stops being ready but is still alive.
+	fail = false
+	app.health.Shutdown()
+	code, _, body = ts.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, `"status":"shutting_down"`)
+
+	code, _, _ = ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestHealthcheckTarget(t *testing.T) {
+	tests := []struct {
+		name           string
+		conf           func(c *config)
+		wantURL        string
+		wantServerName string
+	}{
+		{
+			name: "Development certificate",
+			conf: func(c *config) {
+				c.Env = "development"
+				c.TLS.DevCert = true
+			},
+			wantURL:        "https://127.0.0.1:4000/readyz",
+			wantServerName: "localhost",
+		},
+		{
+			name:           "Development without -tls-dev-cert",
+			conf:           func(c *config) { c.Env = "development" },
+			wantURL:        "http://127.0.0.1:4000/readyz",
+			wantServerName: "localhost",
+		},
+		{
+			name: "-tls-dev-cert outside development",
+			conf: func(c *config) {
+				c.Env = "production"
+				c.TLS.DevCert = true
+			},
+			wantURL:        "http://127.0.0.1:4000/readyz",
+			wantServerName: "localhost",
+		},
+		{
+			name:           "Plain HTTP",
+			conf:           func(c *config) { c.Env = "production" },
+			wantURL:        "http://127.0.0.1:4000/readyz",
+			wantServerName: "localhost",
+		},
+		{
+			name: "Certificate files",
+			conf: func(c *config) {
+				c.Env = "production"
+				c.TLS.Cert = "cert.pem"
+				c.TLS.Key = "key.pem"
+			},
+			wantURL:        "https://127.0.0.1:4000/readyz",
+			wantServerName: "localhost",
+		},
+		{
+			name: "ACME",
+			conf: func(c *config) {
+				c.Env = "production"
+				c.ACME.Domains = []string{"example.com", "www.example.com"}
+			},
+			wantURL:        "https://127.0.0.1:4000/readyz",
+			wantServerName: "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{Port: 4000}
+			tt.conf(&conf)
+
+			url, serverName := healthcheckTarget(conf)
+			assert.Equal(t, url, tt.wantURL)
+			assert.Equal(t, serverName, tt.wantServerName)
+		})
+	}
+}
+
+func TestRunHealthcheck(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	assert.NilError(t, err)
+
+	conf := config{Port: port, Env: "development"}
+	conf.TLS.DevCert = true
+	assert.NilError(t, runHealthcheck(conf))
+
+	app.health.Shutdown()
+	assert.Equal(t, runHealthcheck(conf) != nil, true)
+}
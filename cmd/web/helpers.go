@@ -2,21 +2,59 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"log/slog"
+	"html/template"
+	"net"
 	"net/http"
-	"runtime/debug"
+	"net/netip"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/validator"
 )
 
+var errSessionStoreNotIterable = errors.New("session store doesn't support iterating over sessions")
+
+// clientIP returns the IP address of the client that made the request, as worked out by the resolveClientIP middleware.
+// If the middleware hasn't run, the address of the connection is used instead.
+func (app *application) clientIP(r *http.Request) string {
+	ip, ok := r.Context().Value(clientIPContextKey).(netip.Addr)
+	if ok && ip.IsValid() {
+		return ip.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // render renders the specified template if it exists.
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
 	// Retrieve the appropriate template set from the cache based on the page name.
 	// If no entry exists in the cache with the provided name, then create a new error and call serverErrorHandler() and return.
-	ts, ok := app.templateCache[page]
-	if !ok {
-		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverErrorHandler(w, r, err)
-		return
+	// When the templates are being read from disk, the page is parsed again on every request instead, so changes show up straight away.
+	var ts *template.Template
+	if app.devTemplates {
+		var err error
+		ts, err = parseTemplate(app.files, page)
+		if err != nil {
+			// The error page is parsed from the same templates, so it would most likely fail too (and call render() again).
+			// Show the parse error itself instead, which is more useful while working on the templates anyway.
+			app.logError(r, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		var ok bool
+		ts, ok = app.templateCache[page]
+		if !ok {
+			err := fmt.Errorf("the template %s does not exist", page)
+			app.serverErrorHandler(w, r, err)
+			return
+		}
 	}
 
 	// Write the template to the buffer, instead of straight to the http.ResponseWriter.
@@ -38,27 +76,56 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	}
 }
 
-// background accepts an arbitrary function as a parameter.
-func (app *application) background(r *http.Request, fn func()) {
-	app.wg.Add(1)
-	// Launch a background goroutine.
-	go func() {
-		// Use "defer" to decrement the WaitGroup counter right before the goroutine returns.
-		defer app.wg.Done()
-
-		// Recover any panics that arise.
-		defer func() {
-			if err := recover(); err != nil {
-				var (
-					method = r.Method
-					trace  = string(debug.Stack())
-					uri    = r.URL.RequestURI()
-				)
-				app.logger.Error(fmt.Sprintf("%v", err), slog.String("method", method), slog.String("uri", uri), slog.String("trace", trace))
+// isAuthenticated returns true if the current request is from an authenticated user.
+func (app *application) isAuthenticated(r *http.Request) bool {
+	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+	return isAuthenticated
+}
+
+// authenticatedUser returns the user added to the request context by the authenticate middleware.
+// If the request isn't from an authenticated user, ok is false.
+func (app *application) authenticatedUser(r *http.Request) (data.User, bool) {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(data.User)
+	return user, ok
+}
+
+// destroyUserSessions deletes every session in the store that belongs to the user with the given ID.
+// This logs the user out everywhere, such as after their password has been reset.
+// Note: this has to load every session in the store, which is fine for most sites, but worth keeping in mind.
+func (app *application) destroyUserSessions(userID int) error {
+	store, ok := app.sessionManager.Store.(scs.IterableStore)
+	if !ok {
+		return errSessionStoreNotIterable
+	}
+
+	sessions, err := store.All()
+	if err != nil {
+		return err
+	}
+
+	for token, b := range sessions {
+		_, values, err := app.sessionManager.Codec.Decode(b)
+		if err != nil {
+			return err
+		}
+
+		// The tokens returned by All() are exactly what's in the store (i.e. already hashed), so delete them straight from the store.
+		if id, ok := values["authenticatedUserID"].(int); ok && id == userID {
+			err = app.sessionManager.Store.Delete(token)
+			if err != nil {
+				return err
 			}
-		}()
+		}
+	}
+
+	return nil
+}
 
-		// Execute our arbitrary function that was passed as the parameter.
-		fn()
-	}()
+// validPlaintextToken returns true if the plaintext token looks like one generated by the data package.
+// This lets obviously invalid tokens be rejected without querying the database.
+func validPlaintextToken(token string) bool {
+	return validator.NotBlank(token) && len(token) == 26
 }
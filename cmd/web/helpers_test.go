@@ -1,39 +1,46 @@
 package main
 
 import (
-	"net/http"
 	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
 )
 
-func TestBackground(t *testing.T) {
-	req, err := http.NewRequest(http.MethodGet, "/", nil)
-	if err != nil {
-		t.Fatal(err)
+func TestDestroyUserSessions(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Add sessions for two different users, and one that isn't logged in.
+	sessions := map[string]map[string]any{
+		"user1-a": {"authenticatedUserID": 1},
+		"user1-b": {"authenticatedUserID": 1},
+		"user2":   {"authenticatedUserID": 2},
+		"anon":    {"flash": "hello"},
 	}
-	tests := []struct {
-		name string
-		r    *http.Request
-		fn   func()
-	}{
-		{
-			name: "Background works normally",
-			r:    req,
-			fn: func() {
-				t.Log("This is a background test")
-			},
-		},
-		{
-			name: "Background recovers from panics",
-			r:    req,
-			fn: func() {
-				panic("this is a panic")
-			},
-		},
+	for token, values := range sessions {
+		b, err := app.sessionManager.Codec.Encode(time.Now().Add(time.Hour), values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = app.sessionManager.Store.Commit(token, b, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			app := newTestApplication(t)
-			app.background(tt.r, tt.fn)
-		})
+
+	err := app.destroyUserSessions(1)
+	assert.NilError(t, err)
+
+	// Only the sessions belonging to user 1 should be gone.
+	for token := range sessions {
+		_, found, err := app.sessionManager.Store.Find(token)
+		assert.NilError(t, err)
+		assert.Equal(t, found, token != "user1-a" && token != "user1-b")
 	}
 }
+
+func TestValidPlaintextToken(t *testing.T) {
+	assert.Equal(t, validPlaintextToken("Y3QMGX3PJ3WLRL2YRTQGQ6KRHU"), true)
+	assert.Equal(t, validPlaintextToken(""), false)
+	assert.Equal(t, validPlaintextToken("tooShort"), false)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/jobs"
+)
+
+// The names of the background jobs the site runs.
+const (
+	jobSendPasswordResetEmail = "send_password_reset_email"
+	jobSendVerificationEmail  = "send_verification_email"
+)
+
+// How long the tokens emailed by the jobs are valid for.
+const (
+	passwordResetTokenTTL = time.Hour
+	verificationTokenTTL  = 3 * 24 * time.Hour
+)
+
+// sendPasswordResetEmailPayload is the payload for jobSendPasswordResetEmail.
+// The token is generated when the job is enqueued, so every attempt emails the same link, rather than storing a new token.
+type sendPasswordResetEmailPayload struct {
+	Email       string    `json:"email"`
+	Token       string    `json:"token"`
+	TokenExpiry time.Time `json:"token_expiry"`
+}
+
+// sendVerificationEmailPayload is the payload for jobSendVerificationEmail.
+// Like sendPasswordResetEmailPayload, the token is generated when the job is enqueued.
+type sendVerificationEmailPayload struct {
+	UserID       int       `json:"user_id"`
+	TemplateFile string    `json:"template_file"`
+	Token        string    `json:"token"`
+	TokenExpiry  time.Time `json:"token_expiry"`
+}
+
+// newSendPasswordResetEmailPayload returns the payload for a job emailing a password reset link to email, with a new token.
+func newSendPasswordResetEmailPayload(email string) (sendPasswordResetEmailPayload, error) {
+	token, err := data.GeneratePlaintext()
+	if err != nil {
+		return sendPasswordResetEmailPayload{}, err
+	}
+	return sendPasswordResetEmailPayload{Email: email, Token: token, TokenExpiry: time.Now().Add(passwordResetTokenTTL)}, nil
+}
+
+// newSendVerificationEmailPayload returns the payload for a job emailing a verification link to the user, with a new token.
+func newSendVerificationEmailPayload(userID int, templateFile string) (sendVerificationEmailPayload, error) {
+	token, err := data.GeneratePlaintext()
+	if err != nil {
+		return sendVerificationEmailPayload{}, err
+	}
+	return sendVerificationEmailPayload{
+		UserID:       userID,
+		TemplateFile: templateFile,
+		Token:        token,
+		TokenExpiry:  time.Now().Add(verificationTokenTTL),
+	}, nil
+}
+
+// registerJobs registers the handlers for all the background jobs with the job queue.
+func (app *application) registerJobs() {
+	jobs.Register(app.jobs, jobSendPasswordResetEmail, app.sendPasswordResetEmailJob)
+	jobs.Register(app.jobs, jobSendVerificationEmail, app.sendVerificationEmailJob)
+}
+
+// sendPasswordResetEmailJob emails a password reset link to the user with the given email address, if one exists.
+func (app *application) sendPasswordResetEmailJob(ctx context.Context, payload sendPasswordResetEmailPayload) error {
+	user, err := app.models.Users.GetByEmail(payload.Email)
+	if err != nil {
+		// If there's no account for the email address, there's nothing to do.
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	err = app.models.Tokens.InsertPlaintext(payload.Token, user.ID, payload.TokenExpiry, data.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	emailData := map[string]any{
+		"name":     user.Name,
+		"resetURL": app.config.BaseURL + "/user/password/reset?token=" + url.QueryEscape(payload.Token),
+		"siteName": app.config.SiteName,
+	}
+	return app.mailer.Send(user.Email, "password-reset.tmpl", emailData)
+}
+
+// sendVerificationEmailJob stores the payload's verification token for the user and emails it to them, using the given email template.
+// The template is given the user's name, the verification URL, and the site name.
+func (app *application) sendVerificationEmailJob(ctx context.Context, payload sendVerificationEmailPayload) error {
+	user, err := app.models.Users.Get(payload.UserID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	// The user may have verified their email address while the job was waiting to run.
+	if user.IsVerified() {
+		return nil
+	}
+
+	err = app.models.Tokens.InsertPlaintext(payload.Token, user.ID, payload.TokenExpiry, data.ScopeVerification)
+	if err != nil {
+		return err
+	}
+
+	emailData := map[string]any{
+		"name":      user.Name,
+		"siteName":  app.config.SiteName,
+		"verifyURL": app.config.BaseURL + "/user/verify?token=" + url.QueryEscape(payload.Token),
+	}
+	return app.mailer.Send(user.Email, payload.TemplateFile, emailData)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/requestid"
+)
+
+// newLogger returns a structured logger that writes to w in the given format (text or json).
+// Records below level are discarded, and level can be changed while the site is running.
+// Records logged with a request's context include its request ID.
+func newLogger(w io.Writer, format string, level *slog.LevelVar) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (must be text or json)", format)
+	}
+
+	return slog.New(requestid.NewHandler(handler)), nil
+}
+
+// parseLogLevel parses a level such as "debug", "info", "warn", or "error".
+// Offsets like "info+2" are also accepted, as understood by slog.Level.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q (must be debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// logLevelHandler shows the current log level for GET requests, and changes it for PUT requests.
+// The new level is given by the "level" parameter, i.e. curl -X PUT -d level=debug http://localhost:4001/debug/log-level
+func (app *application) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		level, err := parseLogLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		app.logLevel.Set(level)
+		app.logger.InfoContext(r.Context(), "log level changed", slog.String("level", level.String()))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintln(w, app.logLevel.Level())
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "log/slog"
+
+// watchLogLevel does nothing, as there's no SIGUSR1 on this platform. The log level can still be changed through the admin listener.
+func (app *application) watchLogLevel(configured slog.Level) (stop func()) {
+	return func() {}
+}
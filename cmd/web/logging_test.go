@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Text",
+			format: "text",
+			want:   "level=WARN msg=hello",
+		},
+		{
+			name:   "JSON",
+			format: "json",
+			want:   `"level":"WARN","msg":"hello"`,
+		},
+		{
+			name:    "Invalid",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			level := new(slog.LevelVar)
+			level.Set(slog.LevelWarn)
+
+			logger, err := newLogger(&buf, tt.format, level)
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+			assert.NilError(t, err)
+
+			logger.Info("ignored")
+			logger.Warn("hello")
+			assert.StringContains(t, buf.String(), tt.want)
+			assert.Equal(t, strings.Contains(buf.String(), "ignored"), false)
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{input: "debug", want: slog.LevelDebug},
+		{input: "INFO", want: slog.LevelInfo},
+		{input: "warn", want: slog.LevelWarn},
+		{input: "error", want: slog.LevelError},
+		{input: "info+2", want: slog.LevelInfo + 2},
+		{input: "loud", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			level, err := parseLogLevel(tt.input)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, level, tt.want)
+		})
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.Admin.Token = "secret"
+
+	ts := newTestServer(t, app.adminRoutes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		method    string
+		token     string
+		level     string
+		wantCode  int
+		wantLevel slog.Level
+	}{
+		{
+			name:      "Missing token",
+			method:    http.MethodGet,
+			wantCode:  http.StatusUnauthorized,
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:      "Wrong token",
+			method:    http.MethodPut,
+			token:     "guess",
+			level:     "debug",
+			wantCode:  http.StatusUnauthorized,
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:      "Show",
+			method:    http.MethodGet,
+			token:     "secret",
+			wantCode:  http.StatusOK,
+			wantLevel: slog.LevelInfo,
+		},
+		{
+			name:      "Change",
+			method:    http.MethodPut,
+			token:     "secret",
+			level:     "debug",
+			wantCode:  http.StatusOK,
+			wantLevel: slog.LevelDebug,
+		},
+		{
+			name:      "Invalid level",
+			method:    http.MethodPut,
+			token:     "secret",
+			level:     "loud",
+			wantCode:  http.StatusBadRequest,
+			wantLevel: slog.LevelDebug,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"level": {tt.level}}
+			req, err := http.NewRequest(tt.method, ts.URL+"/debug/log-level", strings.NewReader(form.Encode()))
+			assert.NilError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rs, err := ts.Client().Do(req)
+			assert.NilError(t, err)
+			_ = rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, app.logLevel.Level(), tt.wantLevel)
+		})
+	}
+}
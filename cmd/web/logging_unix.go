@@ -0,0 +1,40 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLogLevel toggles the log level between debug and the configured level whenever a SIGUSR1 is received,
+// until the returned function is called. This turns on debug logging in production without a restart.
+func (app *application) watchLogLevel(configured slog.Level) (stop func()) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-usr1:
+				level := slog.LevelDebug
+				if app.logLevel.Level() == slog.LevelDebug {
+					level = configured
+				}
+				app.logLevel.Set(level)
+				app.logger.Log(context.Background(), max(level, slog.LevelInfo), "log level changed", slog.String("level", level.String()))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(usr1)
+		close(done)
+	}
+}
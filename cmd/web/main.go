@@ -5,9 +5,10 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log/slog"
 	"os"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/scs/pgxstore"
@@ -15,34 +16,172 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rynhndrcksn/go-starter-site/internal/data"
 	"github.com/rynhndrcksn/go-starter-site/internal/env"
+	"github.com/rynhndrcksn/go-starter-site/internal/handoff"
+	"github.com/rynhndrcksn/go-starter-site/internal/health"
+	"github.com/rynhndrcksn/go-starter-site/internal/jobs"
+	"github.com/rynhndrcksn/go-starter-site/internal/lifecycle"
+	"github.com/rynhndrcksn/go-starter-site/internal/mailer"
+	"github.com/rynhndrcksn/go-starter-site/internal/ratelimit"
+	"github.com/rynhndrcksn/go-starter-site/internal/realip"
 	"github.com/rynhndrcksn/go-starter-site/internal/vcs"
+	"github.com/rynhndrcksn/go-starter-site/ui"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // config contains all the project configuration.
+// Values are loaded from an optional configuration file, then environment variables using the struct tags,
+// and can then be overridden by command-line flags. Fields tagged with secret:"true" are redacted by -print-config.
 type config struct {
-	port int
-	env  string
-	dsn  string
+	Port           int           `env:"PORT" default:"4000"`
+	Env            string        `env:"ENV" default:"development"`
+	DSN            string        `env:"DB_CONN" secret:"true"`
+	BaseURL        string        `env:"BASE_URL" default:"https://localhost:4000"`
+	SiteName       string        `env:"SITE_NAME" default:"Site"`
+	Debug          bool          `env:"DEBUG" default:"false"`
+	DevTemplates   string        `env:"DEV_TEMPLATES"`
+	MigrateOnStart bool          `env:"MIGRATE_ON_START" default:"false"`
+	TrustedProxies []string      `env:"TRUSTED_PROXIES"`
+	ReadyCache     time.Duration `env:"READY_CACHE" default:"2s"`
+	Admin          struct {
+		Addr     string `env:"ADMIN_ADDR" default:"localhost:4001"`
+		Token    string `env:"ADMIN_TOKEN" secret:"true"`
+		Username string `env:"ADMIN_USERNAME"`
+		Password string `env:"ADMIN_PASSWORD" secret:"true"`
+	}
+	Jobs struct {
+		Workers      int           `env:"JOBS_WORKERS" default:"2"`
+		MaxAttempts  int           `env:"JOBS_MAX_ATTEMPTS" default:"5"`
+		PollInterval time.Duration `env:"JOBS_POLL_INTERVAL" default:"1s"`
+		Retention    time.Duration `env:"JOBS_RETENTION" default:"168h"`
+	}
+	Shutdown struct {
+		DrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" default:"0s"`
+		Timeout    time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	}
+	Log struct {
+		Format    string   `env:"LOG_FORMAT" default:"text"`
+		Level     string   `env:"LOG_LEVEL" default:"info"`
+		SkipPaths []string `env:"LOG_SKIP_PATHS" default:"/static/"`
+	}
+	TLS struct {
+		Cert         string `env:"TLS_CERT"`
+		Key          string `env:"TLS_KEY"`
+		RedirectPort int    `env:"TLS_REDIRECT_PORT" default:"0"`
+		ACMEWebroot  string `env:"TLS_ACME_WEBROOT"`
+		DevCert      bool   `env:"TLS_DEV_CERT" default:"false"`
+		DevCertDir   string `env:"TLS_DEV_CERT_DIR"`
+	}
+	ACME struct {
+		Domains     []string      `env:"ACME_DOMAINS"`
+		Email       string        `env:"ACME_EMAIL"`
+		Directory   string        `env:"ACME_DIRECTORY" default:"https://acme-v02.api.letsencrypt.org/directory"`
+		CacheDir    string        `env:"ACME_CACHE_DIR"`
+		CACert      string        `env:"ACME_CA_CERT"`
+		RenewBefore time.Duration `env:"ACME_RENEW_BEFORE" default:"720h"`
+	}
+	RateLimit struct {
+		Enabled   bool    `env:"RATE_LIMIT_ENABLED" default:"true"`
+		RPS       float64 `env:"RATE_LIMIT_RPS" default:"10"`
+		Burst     int     `env:"RATE_LIMIT_BURST" default:"40"`
+		ByUser    bool    `env:"RATE_LIMIT_BY_USER" default:"false"`
+		AuthRPS   float64 `env:"RATE_LIMIT_AUTH_RPS" default:"0.1"`
+		AuthBurst int     `env:"RATE_LIMIT_AUTH_BURST" default:"5"`
+	}
+	SMTP struct {
+		Host     string `env:"SMTP_HOST" default:"localhost"`
+		Port     int    `env:"SMTP_PORT" default:"1025"`
+		Username string `env:"SMTP_USERNAME"`
+		Password string `env:"SMTP_PASSWORD" secret:"true"`
+		Sender   string `env:"SMTP_SENDER" default:"Site <no-reply@example.com>"`
+	}
 }
 
 // application contains the stuff used across the project.
 type application struct {
-	config         config
-	debug          bool
-	logger         *slog.Logger
-	wg             sync.WaitGroup
-	templateCache  map[string]*template.Template
-	sessionManager *scs.SessionManager
-	models         data.Models
+	authRateLimiter *ratelimit.Limiter
+	certManager     *autocert.Manager
+	config          config
+	debug           bool
+	devTemplates    bool
+	files           fs.FS
+	health          *health.Checker
+	jobs            *jobs.Queue
+	listeners       *handoff.Listeners
+	lifecycle       *lifecycle.Manager
+	logger          *slog.Logger
+	logLevel        *slog.LevelVar
+	mailer          *mailer.Mailer
+	metrics         *siteMetrics
+	templateCache   map[string]*template.Template
+	sessionManager  *scs.SessionManager
+	models          data.Models
+	rateLimiter     *ratelimit.Limiter
+	realIP          realip.Resolver
 }
 
 func main() {
+	// Load the configuration from the file given by -config (if any), and then the environment.
+	// The file has to be known before the rest of the flags are defined, as they use the loaded values as their defaults.
+	// Any missing or malformed values are all reported at once, so they can be fixed in one go.
 	var conf config
-	flag.IntVar(&conf.port, "port", env.GetIntOrDefault("PORT", 4000), "Web server port")
-	flag.StringVar(&conf.env, "env", env.GetStringOrDefault("ENV", "development"), "Environment (development|staging|production)")
-	flag.StringVar(&conf.dsn, "dsn", env.GetStringOrDefault("DB_CONN", ""), "Database DSN")
-	debug := flag.Bool("debug", env.GetBoolOrDefault("DEBUG", false), "Enable debug mode")
+	configFile := configPath(os.Args[1:])
+	err := env.LoadFile(&conf, configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	// Command-line flags take precedence over the environment, so use the loaded values as their defaults.
+	flag.String("config", configFile, "Path to a JSON or TOML configuration file")
+	flag.IntVar(&conf.Port, "port", conf.Port, "Web server port")
+	flag.StringVar(&conf.Env, "env", conf.Env, "Environment (development|staging|production)")
+	flag.StringVar(&conf.DSN, "dsn", conf.DSN, "Database DSN")
+	flag.StringVar(&conf.BaseURL, "base-url", conf.BaseURL, "Base URL used for links in emails")
+	flag.StringVar(&conf.SiteName, "site-name", conf.SiteName, "Name of the site, used in page titles and emails")
+	flag.StringVar(&conf.TLS.Cert, "tls-cert", conf.TLS.Cert, "Path to a PEM encoded TLS certificate, which enables HTTPS (reloaded when changed, or on SIGHUP)")
+	flag.StringVar(&conf.TLS.Key, "tls-key", conf.TLS.Key, "Path to the PEM encoded private key for -tls-cert")
+	flag.IntVar(&conf.TLS.RedirectPort, "tls-redirect-port", conf.TLS.RedirectPort, "Port for a plain HTTP listener that redirects to HTTPS, i.e. 80 (0 disables it)")
+	flag.StringVar(&conf.TLS.ACMEWebroot, "tls-acme-webroot", conf.TLS.ACMEWebroot, "Directory to serve /.well-known/acme-challenge/ from on the redirect listener, i.e. for certbot --webroot")
+	flag.BoolVar(&conf.TLS.DevCert, "tls-dev-cert", conf.TLS.DevCert, "Serve HTTPS with a generated localhost certificate, when no other certificate is configured (development only)")
+	flag.StringVar(&conf.TLS.DevCertDir, "tls-dev-cert-dir", conf.TLS.DevCertDir, "Directory for the development CA and localhost certificate (defaults to the user cache directory)")
+	flag.Var((*commaList)(&conf.ACME.Domains), "acme-domains", "Comma separated domains to automatically obtain certificates for using ACME, which enables HTTPS")
+	flag.StringVar(&conf.ACME.Email, "acme-email", conf.ACME.Email, "Contact email for the ACME account")
+	flag.StringVar(&conf.ACME.Directory, "acme-directory", conf.ACME.Directory, "ACME directory URL, i.e. Let's Encrypt staging, or a local Pebble server")
+	flag.StringVar(&conf.ACME.CacheDir, "acme-cache-dir", conf.ACME.CacheDir, "Directory to cache ACME certificates in (defaults to the database)")
+	flag.StringVar(&conf.ACME.CACert, "acme-ca-cert", conf.ACME.CACert, "PEM encoded CA certificate to trust for the ACME directory, i.e. Pebble's")
+	flag.DurationVar(&conf.ACME.RenewBefore, "acme-renew-before", conf.ACME.RenewBefore, "How long before expiry ACME certificates are renewed")
+	flag.BoolVar(&conf.RateLimit.Enabled, "rate-limit", conf.RateLimit.Enabled, "Enable per-client rate limiting")
+	flag.Float64Var(&conf.RateLimit.RPS, "rate-limit-rps", conf.RateLimit.RPS, "Requests per second allowed for each client")
+	flag.IntVar(&conf.RateLimit.Burst, "rate-limit-burst", conf.RateLimit.Burst, "Requests each client can burst above -rate-limit-rps")
+	flag.BoolVar(&conf.RateLimit.ByUser, "rate-limit-by-user", conf.RateLimit.ByUser, "Rate limit logged in users by their user ID, rather than their IP address")
+	flag.Float64Var(&conf.RateLimit.AuthRPS, "rate-limit-auth-rps", conf.RateLimit.AuthRPS, "Requests per second allowed for each client on sensitive routes, such as logging in")
+	flag.IntVar(&conf.RateLimit.AuthBurst, "rate-limit-auth-burst", conf.RateLimit.AuthBurst, "Requests each client can burst above -rate-limit-auth-rps")
+	flag.StringVar(&conf.SMTP.Host, "smtp-host", conf.SMTP.Host, "SMTP host")
+	flag.IntVar(&conf.SMTP.Port, "smtp-port", conf.SMTP.Port, "SMTP port")
+	flag.StringVar(&conf.SMTP.Username, "smtp-username", conf.SMTP.Username, "SMTP username")
+	flag.StringVar(&conf.SMTP.Password, "smtp-password", conf.SMTP.Password, "SMTP password")
+	flag.StringVar(&conf.SMTP.Sender, "smtp-sender", conf.SMTP.Sender, "SMTP sender")
+	flag.IntVar(&conf.Jobs.Workers, "jobs-workers", conf.Jobs.Workers, "Number of background job workers")
+	flag.IntVar(&conf.Jobs.MaxAttempts, "jobs-max-attempts", conf.Jobs.MaxAttempts, "Maximum attempts for a background job before it's marked dead")
+	flag.DurationVar(&conf.Jobs.PollInterval, "jobs-poll-interval", conf.Jobs.PollInterval, "How often idle job workers check for new jobs")
+	flag.DurationVar(&conf.Jobs.Retention, "jobs-retention", conf.Jobs.Retention, "How long completed and dead jobs are kept before they're deleted (0 keeps them forever)")
+	flag.Var((*commaList)(&conf.TrustedProxies), "trusted-proxies", "Comma separated CIDRs of proxies trusted to set the client IP with X-Forwarded-For, X-Real-IP, or Forwarded")
+	flag.DurationVar(&conf.ReadyCache, "ready-cache", conf.ReadyCache, "How long /readyz reuses the result of the readiness checks for")
+	flag.StringVar(&conf.Log.Format, "log-format", conf.Log.Format, "Log format (text|json)")
+	flag.StringVar(&conf.Log.Level, "log-level", conf.Log.Level, "Minimum log level (debug|info|warn|error), which can be toggled to debug with SIGUSR1")
+	flag.Var((*commaList)(&conf.Log.SkipPaths), "log-skip-paths", "Comma separated path prefixes that aren't logged, i.e. /static/")
+	flag.StringVar(&conf.Admin.Addr, "admin-addr", conf.Admin.Addr, "Address for the admin listener, which serves metrics, pprof, and other operational endpoints (empty disables it)")
+	flag.StringVar(&conf.Admin.Token, "admin-token", conf.Admin.Token, "Bearer token for the admin listener")
+	flag.StringVar(&conf.Admin.Username, "admin-username", conf.Admin.Username, "Basic auth username for the admin listener")
+	flag.StringVar(&conf.Admin.Password, "admin-password", conf.Admin.Password, "Basic auth password for the admin listener")
+	flag.DurationVar(&conf.Shutdown.DrainDelay, "shutdown-drain-delay", conf.Shutdown.DrainDelay, "How long to keep serving after failing readiness checks, before shutting down (i.e. 5s behind a load balancer)")
+	flag.DurationVar(&conf.Shutdown.Timeout, "shutdown-timeout", conf.Shutdown.Timeout, "How long to wait for requests and jobs to finish when shutting down")
+	flag.BoolVar(&conf.MigrateOnStart, "migrate-on-start", conf.MigrateOnStart, "Apply any pending database migrations before starting the server")
+	flag.BoolVar(&conf.Debug, "debug", conf.Debug, "Enable debug mode")
+	flag.StringVar(&conf.DevTemplates, "dev-templates", conf.DevTemplates, "Directory to read templates and static files from, instead of the embedded copies, re-parsing templates on every request (defaults to ./ui in development, if it exists)")
 	displayVersion := flag.Bool("version", false, "Display version and exit")
+	printConfig := flag.Bool("print-config", false, "Display the configuration, with secrets redacted, and exit")
+	flag.Usage = usage
 	flag.Parse()
 
 	if *displayVersion {
@@ -50,11 +189,123 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *printConfig {
+		err = env.Dump(os.Stdout, conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Parse the trusted proxies up front, so a typo stops the site from starting rather than silently trusting nothing.
+	trustedProxies, err := realip.ParsePrefixes(conf.TrustedProxies)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// A certificate is useless without its key, and vice versa.
+	if (conf.TLS.Cert == "") != (conf.TLS.Key == "") {
+		fmt.Fprintln(os.Stderr, "-tls-cert and -tls-key must be used together")
+		os.Exit(2)
+	}
+	if conf.TLS.Cert != "" && len(conf.ACME.Domains) > 0 {
+		fmt.Fprintln(os.Stderr, "-tls-cert and -acme-domains can't be used together")
+		os.Exit(2)
+	}
+	if conf.TLS.RedirectPort != 0 && conf.TLS.Cert == "" && len(conf.ACME.Domains) == 0 {
+		fmt.Fprintln(os.Stderr, "-tls-redirect-port requires -tls-cert and -tls-key, or -acme-domains")
+		os.Exit(2)
+	}
+
+	// Check the logging configuration before the logger is needed.
+	logLevel := new(slog.LevelVar)
+	configuredLevel, err := parseLogLevel(conf.Log.Level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logLevel.Set(configuredLevel)
+
+	if (conf.Admin.Username == "") != (conf.Admin.Password == "") {
+		fmt.Fprintln(os.Stderr, "-admin-username and -admin-password must be used together")
+		os.Exit(2)
+	}
+
+	// Make sure any subcommand is one we know about before doing anything else.
+	command := flag.Arg(0)
+	if command != "" && command != "migrate" && command != "devcert" && command != "healthcheck" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	// Initialize new structured logger that writes to stdout.
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	logger, err := newLogger(os.Stdout, conf.Log.Format, logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Handle the "devcert" subcommand, which doesn't need the database.
+	if command == "devcert" {
+		err = runDevcert(conf.TLS.DevCertDir)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Handle the "healthcheck" subcommand, which talks to the running site rather than the database.
+	if command == "healthcheck" {
+		err = runHealthcheck(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Pick up any listeners handed over by a previous process (see watchRestart()), or by systemd socket activation.
+	listeners, err := handoff.New("main", "admin", "redirect")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if listeners.Inherited() {
+		logger.Info("using inherited listeners")
+	}
+
+	// Serve HTTPS in development using a locally generated certificate, unless one has been configured.
+	if command == "" && useDevcert(conf) {
+		files, generated, err := ensureDevcert(conf.TLS.DevCertDir)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if generated {
+			logger.Info("generated development certificate", slog.String("ca", files.CACert))
+		}
+		conf.TLS.Cert = files.Cert
+		conf.TLS.Key = files.Key
+	}
+
+	// In development, read the templates and static files from disk, so changes show up without rebuilding.
+	devTemplates, err := devTemplatesDir(conf)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(2)
+	}
+	var files fs.FS = ui.Files
+	if devTemplates != "" {
+		files = os.DirFS(devTemplates)
+		logger.Info("reading templates and static files from disk", slog.String("dir", devTemplates))
+	}
 
 	// Initialize a new template cache.
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(files)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
@@ -69,6 +320,28 @@ func main() {
 	defer db.Close()
 	logger.Info("database connection pool established")
 
+	// Handle the "migrate" subcommand, which exits once the migrations are done.
+	if command == "migrate" {
+		err = runMigrate(db, logger, flag.Args()[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			db.Close()
+			os.Exit(1)
+		}
+		db.Close()
+		os.Exit(0)
+	}
+
+	// Apply any pending migrations before anything else uses the database.
+	if conf.MigrateOnStart {
+		err = runMigrate(db, logger, []string{"up"})
+		if err != nil {
+			logger.Error(err.Error())
+			db.Close()
+			os.Exit(1)
+		}
+	}
+
 	// Initialize a new session manager with an in memory session store.
 	// Documentation can be found here: https://pkg.go.dev/github.com/alexedwards/scs/v2
 	sessionManager := scs.New()
@@ -77,16 +350,96 @@ func main() {
 	sessionManager.HashTokenInStore = true
 	sessionManager.Store = pgxstore.New(db)
 
+	// Initialize a new mailer that sends emails rendered from the templates in ui/html/email/.
+	transport := mailer.SMTPTransport{
+		Host:     conf.SMTP.Host,
+		Port:     conf.SMTP.Port,
+		Username: conf.SMTP.Username,
+		Password: conf.SMTP.Password,
+	}
+
+	// Initialize a new Postgres backed job queue.
+	models := data.NewModels(db)
+	jobQueue := jobs.New(models.Jobs, logger, jobs.Config{
+		Workers:      conf.Jobs.Workers,
+		PollInterval: conf.Jobs.PollInterval,
+		MaxAttempts:  conf.Jobs.MaxAttempts,
+		Retention:    conf.Jobs.Retention,
+	})
+
+	// Initialize a new ACME certificate manager, if certificates should be obtained automatically.
+	var certManager *autocert.Manager
+	if len(conf.ACME.Domains) > 0 {
+		certManager, err = newCertManager(conf, models.ACMECache)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Initialize the readiness checks, which make sure the database can be reached.
+	checker := health.New()
+	checker.Add("database", 2*time.Second, db.Ping)
+
+	// Initialize the rate limiters, which are left nil if rate limiting is disabled.
+	var rateLimiter, authRateLimiter *ratelimit.Limiter
+	if conf.RateLimit.Enabled {
+		rateLimiter = ratelimit.New(conf.RateLimit.RPS, conf.RateLimit.Burst)
+		authRateLimiter = ratelimit.New(conf.RateLimit.AuthRPS, conf.RateLimit.AuthBurst)
+	}
+
 	// Initialize a new application struct.
 	app := &application{
-		config:         conf,
-		debug:          *debug,
-		logger:         logger,
-		templateCache:  templateCache,
-		models:         data.NewModels(db),
-		sessionManager: sessionManager,
+		authRateLimiter: authRateLimiter,
+		certManager:     certManager,
+		config:          conf,
+		debug:           conf.Debug,
+		devTemplates:    devTemplates != "",
+		files:           files,
+		health:          checker,
+		jobs:            jobQueue,
+		listeners:       listeners,
+		lifecycle:       lifecycle.New(logger, conf.Shutdown.DrainDelay, conf.Shutdown.Timeout),
+		logger:          logger,
+		logLevel:        logLevel,
+		mailer:          mailer.New(transport, conf.SMTP.Sender, files, templateFunctions(files)),
+		metrics:         newSiteMetrics(db),
+		templateCache:   templateCache,
+		models:          models,
+		rateLimiter:     rateLimiter,
+		realIP:          realip.New(trustedProxies),
+		sessionManager:  sessionManager,
 	}
 
+	// Register what happens when the site shuts down. Readiness checks start failing straight away, so load balancers
+	// stop sending new requests. Once the servers have stopped (see serve()), the shutdown hooks run in reverse order:
+	// job workers are stopped, the rate limiters stop sweeping, and then the database pool is closed.
+	app.lifecycle.OnDrain(app.health.Shutdown)
+	app.lifecycle.OnShutdown("database", func(context.Context) error {
+		db.Close()
+		return nil
+	})
+	if conf.RateLimit.Enabled {
+		app.lifecycle.OnShutdown("rate limiters", func(context.Context) error {
+			rateLimiter.Stop()
+			authRateLimiter.Stop()
+			return nil
+		})
+	}
+
+	// Register the background job handlers and start the workers.
+	app.registerJobs()
+	err = app.jobs.Start()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.lifecycle.OnShutdown("jobs", app.jobs.Shutdown)
+
+	// Toggle debug logging on SIGUSR1.
+	stopWatching := app.watchLogLevel(configuredLevel)
+	defer stopWatching()
+
 	// Launch the site.
 	err = app.serve()
 	if err != nil {
@@ -96,13 +449,70 @@ func main() {
 	os.Exit(0)
 }
 
+// usage prints the help message for the web binary, including its subcommands.
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	_, _ = fmt.Fprintln(out, "Commands:")
+	_, _ = fmt.Fprintln(out, "  devcert                           Generate a local CA and localhost certificate for development")
+	_, _ = fmt.Fprintln(out, "  healthcheck                       Check the running site is ready, for container health checks")
+	_, _ = fmt.Fprintln(out, "  migrate up|down|status|version    Manage the database migrations")
+	_, _ = fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// commaList is a flag.Value for a comma separated list of strings, such as "a.example.com,b.example.com".
+type commaList []string
+
+func (l *commaList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	*l = nil
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// configPath returns the value of the -config flag in args, if there is one.
+// The flag package can't be used for this, since the other flags aren't defined until the configuration is loaded.
+func configPath(args []string) string {
+	for i, arg := range args {
+		// Flags stop at a "--", just like they do for flag.Parse().
+		// Other non-flag arguments are skipped, as they could be the value of a flag before this one.
+		if arg == "--" {
+			return ""
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
 // openDB returns a pgxpool.Pool.
 func openDB(cfg config) (*pgxpool.Pool, error) {
 	// Use pgxpool.New() to create an empty connection pool, using the DSN from the config struct.
 	// Note: pgxpool parses configurations from the connection string.
 	// i.e. postgres://.../myDatabase?sslmode=verify-ca&pool_max_conns=10
 	// More information can be found here: https://pkg.go.dev/github.com/jackc/pgx/v5/pgxpool@v5.6.0#Config
-	db, err := pgxpool.New(context.Background(), cfg.dsn)
+	db, err := pgxpool.New(context.Background(), cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
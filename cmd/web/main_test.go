@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestConfigPath(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "No flags",
+			args: nil,
+			want: "",
+		},
+		{
+			name: "Separate value",
+			args: []string{"-port", "4000", "-config", "site.toml"},
+			want: "site.toml",
+		},
+		{
+			name: "Equals value",
+			args: []string{"--config=site.json", "migrate", "up"},
+			want: "site.json",
+		},
+		{
+			name: "After --",
+			args: []string{"--", "-config", "site.toml"},
+			want: "",
+		},
+		{
+			name: "Missing value",
+			args: []string{"-config"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, configPath(tt.args), tt.want)
+		})
+	}
+}
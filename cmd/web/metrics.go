@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rynhndrcksn/go-starter-site/internal/vcs"
+)
+
+// siteMetrics holds the metrics served at /metrics, in a format Prometheus can scrape.
+type siteMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// newSiteMetrics registers the HTTP, database, runtime, process, and build metrics.
+// The database metrics are left out if db is nil.
+func newSiteMetrics(db *pgxpool.Pool) *siteMetrics {
+	r := prometheus.NewRegistry()
+
+	m := &siteMetrics{
+		registry: r,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests handled, by route pattern and status.",
+		}, []string{"route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "How long HTTP requests took to handle, by route pattern and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being handled.",
+		}),
+	}
+	r.MustRegister(m.requests, m.duration, m.inFlight)
+
+	if db != nil {
+		gauge := func(name, help string, value func(s *pgxpool.Stat) float64) prometheus.Collector {
+			return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+				return value(db.Stat())
+			})
+		}
+		counter := func(name, help string, value func(s *pgxpool.Stat) float64) prometheus.Collector {
+			return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+				return value(db.Stat())
+			})
+		}
+
+		r.MustRegister(
+			gauge("pgxpool_acquired_conns", "Number of connections currently acquired from the pool.", func(s *pgxpool.Stat) float64 {
+				return float64(s.AcquiredConns())
+			}),
+			gauge("pgxpool_idle_conns", "Number of idle connections in the pool.", func(s *pgxpool.Stat) float64 {
+				return float64(s.IdleConns())
+			}),
+			gauge("pgxpool_total_conns", "Total number of connections in the pool.", func(s *pgxpool.Stat) float64 {
+				return float64(s.TotalConns())
+			}),
+			gauge("pgxpool_max_conns", "Maximum size of the pool.", func(s *pgxpool.Stat) float64 {
+				return float64(s.MaxConns())
+			}),
+			counter("pgxpool_acquires_total", "Number of connections acquired from the pool.", func(s *pgxpool.Stat) float64 {
+				return float64(s.AcquireCount())
+			}),
+			counter("pgxpool_empty_acquires_total", "Number of acquires that had to wait for a connection, because the pool was empty.", func(s *pgxpool.Stat) float64 {
+				return float64(s.EmptyAcquireCount())
+			}),
+			counter("pgxpool_acquire_wait_seconds_total", "Total time spent waiting to acquire connections from the pool.", func(s *pgxpool.Stat) float64 {
+				return s.AcquireDuration().Seconds()
+			}),
+		)
+	}
+
+	// The standard go_* and process_* metrics, so existing dashboards and alerts work with the site.
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	build := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "site_build_info",
+		Help:        "Information about the running build of the site.",
+		ConstLabels: prometheus.Labels{"version": vcs.Version(), "goversion": runtime.Version()},
+	})
+	build.Set(1)
+	r.MustRegister(build)
+
+	return m
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (m *siteMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// recordMetrics counts requests and measures how long they take, labelled by the route pattern that handled them
+// and the response status. Using the pattern rather than the path keeps the number of series bounded.
+// This runs outside recoverPanic, so requests that panic are counted as 500s.
+func (app *application) recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.metrics.inFlight.Inc()
+		defer app.metrics.inFlight.Dec()
+
+		// routePattern fills this in once the request reaches the router.
+		pattern := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), routePatternContextKey, pattern))
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		// Requests that are rejected before reaching the router (i.e. by rate limiting) don't have a pattern.
+		route := *pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rw.status)
+		app.metrics.requests.WithLabelValues(route, status).Inc()
+		app.metrics.duration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routePattern records the pattern of the route mux picked for the request, such as "GET /user/login", for recordMetrics.
+// mux sets Request.Pattern on the request it's given, which isn't visible to middleware that ran before a copy was made.
+func (app *application) routePattern(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This is deferred so the pattern is still recorded if the handler panics.
+		defer func() {
+			pattern, ok := r.Context().Value(routePatternContextKey).(*string)
+			if ok {
+				*pattern = r.Pattern
+			}
+		}()
+		mux.ServeHTTP(w, r)
+	})
+}
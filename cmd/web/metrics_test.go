@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestRecordMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	ts.get(t, "/about")
+	ts.get(t, "/about")
+	ts.get(t, "/missing")
+
+	rr := httptest.NewRecorder()
+	app.metrics.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+
+	assert.StringContains(t, body, `http_requests_total{route="GET /about",status="200"} 2`)
+	assert.StringContains(t, body, `http_requests_total{route="GET /",status="404"} 1`)
+	assert.StringContains(t, body, `http_request_duration_seconds_count{route="GET /about",status="200"} 2`)
+	assert.StringContains(t, body, "http_requests_in_flight 0")
+	assert.StringContains(t, body, `site_build_info{goversion="`)
+	assert.StringContains(t, body, "go_goroutines ")
+	assert.StringContains(t, body, "go_memstats_alloc_bytes ")
+}
+
+func TestRecordMetricsPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	handler := app.recordMetrics(app.sessionManager.LoadAndSave(app.recoverPanic(app.routePattern(mux))))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	rr := httptest.NewRecorder()
+	app.metrics.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.StringContains(t, rr.Body.String(), `http_requests_total{route="GET /panic",status="500"} 1`)
+	assert.StringContains(t, rr.Body.String(), "http_requests_in_flight 0")
+}
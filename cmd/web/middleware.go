@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/ratelimit"
+	"github.com/rynhndrcksn/go-starter-site/internal/requestid"
 )
 
 // commonHeaders sets all the default headers we want on every request.
@@ -22,19 +34,68 @@ func (app *application) commonHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// logRequests will log information for each request the site gets.
+// requestID gives every request an ID, which is added to the request context, the response headers, and every log record made
+// with the request context. An ID sent by an upstream proxy in X-Request-ID is used if it looks safe, so requests can be traced
+// across services. Otherwise, a new one is generated.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// resolveClientIP works out the client's real IP address, taking -trusted-proxies into account, and adds it to the request context.
+// It should run before anything that uses app.clientIP(), such as logging and rate limiting.
+func (app *application) resolveClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey, app.realIP.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logRequests will log information for each request the site gets, once it's been handled.
+// Requests for paths starting with any of the -log-skip-paths prefixes (such as static files) aren't logged.
+// This runs outside recoverPanic, so requests that panic are still logged with their 500 status.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range app.config.Log.SkipPaths {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
 		var (
-			ip     = r.RemoteAddr
-			proto  = r.Proto
-			method = r.Method
-			uri    = r.RequestURI
+			ip        = app.clientIP(r)
+			proto     = r.Proto
+			method    = r.Method
+			uri       = r.RequestURI
+			status    = rw.status
+			size      = rw.bytes
+			duration  = time.Since(start)
+			userAgent = r.UserAgent()
+			referer   = r.Referer()
+		)
+		app.logger.InfoContext(r.Context(), "Handled request",
+			slog.String("ip", ip),
+			slog.String("proto", proto),
+			slog.String("method", method),
+			slog.String("uri", uri),
+			slog.Int("status", status),
+			slog.Int("size", size),
+			slog.Duration("duration", duration),
+			slog.String("user_agent", userAgent),
+			slog.String("referer", referer),
 		)
-		if !strings.Contains(uri, "static") {
-			app.logger.Info("Received request", slog.String("ip", ip), slog.String("proto", proto), slog.String("method", method), slog.String("uri", uri))
-		}
-		next.ServeHTTP(w, r)
 	})
 }
 
@@ -52,3 +113,170 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireAuthentication redirects unauthenticated users to the login page.
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// If the user isn't authenticated, redirect them to the login page and return from the middleware chain.
+		if !app.isAuthenticated(r) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// Set the "Cache-Control: no-store" header so pages that require authentication aren't stored in the user's browser cache.
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the session for an authenticated user ID, and if it belongs to a user that still exists,
+// marks the request as authenticated and adds the user to the request context.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// If there's no authenticated user ID in the session, call the next handler in the chain as normal.
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Look up the user with that ID in the database.
+		// If they no longer exist, treat the request as unauthenticated.
+		user, err := app.models.Users.Get(id)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			app.serverErrorHandler(w, r, err)
+			return
+		}
+
+		// Create a copy of the request with the user and isAuthenticatedContextKey set in the request context.
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVerifiedUser only lets authenticated users who have verified their email address through.
+// Unverified users are shown a page explaining how to verify their email address instead.
+func (app *application) requireVerifiedUser(next http.Handler) http.Handler {
+	return app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := app.authenticatedUser(r)
+		if !ok || !user.IsVerified() {
+			app.unverifiedHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// preventCSRF rejects any state-changing request (POST, PUT, PATCH, or DELETE) that doesn't send the session's CSRF token back.
+// The token can be sent as a "csrf_token" form field, or in the "X-CSRF-Token" header for JavaScript requests.
+// Tokens aren't created here, but by the first form that's rendered for the session (see templateData.CSRFToken()).
+// That way, requests for static files, health checks, and pages without forms don't create sessions.
+func (app *application) preventCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token depends on the session cookie, so make sure nothing caches the response.
+		w.Header().Add("Vary", "Cookie")
+
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			token := app.sessionManager.GetString(r.Context(), "csrfToken")
+			sent := r.Header.Get("X-CSRF-Token")
+			if sent == "" {
+				sent = r.PostFormValue("csrf_token")
+			}
+
+			// A session without a token has never been shown a form, so there's nothing it could validly send back.
+			// Use a constant time comparison so the token can't be guessed using a timing attack.
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				app.logger.WarnContext(r.Context(), "CSRF token mismatch", slog.String("method", r.Method), slog.String("uri", r.URL.RequestURI()))
+				app.forbiddenHandler(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfToken returns the session's CSRF token, generating a new one if the session doesn't have one yet.
+func (app *application) csrfToken(r *http.Request) (string, error) {
+	token := app.sessionManager.GetString(r.Context(), "csrfToken")
+	if token != "" {
+		return token, nil
+	}
+
+	token, err := generateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	app.sessionManager.Put(r.Context(), "csrfToken", token)
+	return token, nil
+}
+
+// generateCSRFToken returns a random, URL safe, base64 encoded token.
+func generateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// rateLimit limits how often each client can make requests to the site as a whole.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	return app.limit(app.rateLimiter, next)
+}
+
+// rateLimitAuth applies the stricter limits to sensitive routes, such as logging in, on top of rateLimit.
+// This slows down password guessing, and stops the site from being used to spam people with emails.
+func (app *application) rateLimitAuth(next http.Handler) http.Handler {
+	return app.limit(app.authRateLimiter, next)
+}
+
+// limit takes a token from the client's bucket in limiter, showing a 429 page once they've run out.
+// Clients are identified by their IP address, or by their user ID once they've logged in if -rate-limit-by-user is set.
+// The user ID is read straight from the session, as this runs before authenticate(), so limited clients don't cost a query.
+// The RateLimit-* headers let well-behaved clients slow down before they hit the limit.
+// If limiter is nil, rate limiting is disabled.
+func (app *application) limit(limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + app.clientIP(r)
+		if app.config.RateLimit.ByUser {
+			if id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID"); id != 0 {
+				key = "user:" + strconv.Itoa(id)
+			}
+		}
+
+		res := limiter.Allow(key)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			app.logger.WarnContext(r.Context(), "rate limit exceeded", slog.String("key", key), slog.String("method", r.Method), slog.String("uri", r.URL.RequestURI()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			app.tooManyRequestsHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds rounds d up to a whole number of seconds, as used by the Retry-After and RateLimit-Reset headers.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -2,12 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/ratelimit"
+	"github.com/rynhndrcksn/go-starter-site/internal/realip"
+	"github.com/rynhndrcksn/go-starter-site/internal/requestid"
 )
 
 func TestCommonHeaders(t *testing.T) {
@@ -98,3 +108,380 @@ func TestRecoverPanic(t *testing.T) {
 	// Check that the middleware worked and the response is what's wanted.
 	assert.Equal(t, rs.Header.Get("Connection"), "close")
 }
+
+func TestPreventCSRF(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// Make a GET request to get a valid CSRF token for the session.
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Missing token",
+			csrfToken: "",
+			wantCode:  http.StatusForbidden,
+			wantBody:  "<title>Forbidden - Site</title>",
+		},
+		{
+			name:      "Wrong token",
+			csrfToken: "wrongToken",
+			wantCode:  http.StatusForbidden,
+			wantBody:  "<title>Forbidden - Site</title>",
+		},
+		{
+			name:      "Valid token",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "<title>Login - Site</title>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestPreventCSRFWithoutForm(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// Pages without forms, and static files, don't need a CSRF token, so they mustn't create a session to store one in.
+	for _, path := range []string{"/", "/missing", "/static/css/main.css"} {
+		_, header, _ := ts.get(t, path)
+		assert.Equal(t, header.Get("Set-Cookie"), "")
+	}
+
+	// Without a session, there's no token that could be sent back.
+	form := url.Values{}
+	form.Add("csrf_token", "")
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	// Rendering a form creates the token, and the session to keep it in.
+	_, header, _ := ts.get(t, "/user/login")
+	assert.StringContains(t, header.Get("Set-Cookie"), "session=")
+}
+
+func TestRequireVerifiedUser(t *testing.T) {
+	verifiedAt := time.Now()
+	tests := []struct {
+		name         string
+		user         *data.User
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Unauthenticated",
+			user:         nil,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Unverified",
+			user:     &data.User{ID: 1, Name: "Bob"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Verified",
+			user:     &data.User{ID: 1, Name: "Bob", VerifiedAt: &verifiedAt},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Add the user to the request context, the same way the authenticate middleware does.
+			if tt.user != nil {
+				ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+				ctx = context.WithValue(ctx, authenticatedUserContextKey, *tt.user)
+				r = r.WithContext(ctx)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("OK"))
+			})
+
+			app.sessionManager.LoadAndSave(app.requireVerifiedUser(next)).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.rateLimiter = ratelimit.New(0.001, 3)
+	defer app.rateLimiter.Stop()
+	app.authRateLimiter = ratelimit.New(0.001, 1)
+	defer app.authRateLimiter.Stop()
+	ts := newTestServer(t, app.sessionManager.LoadAndSave(app.routes()))
+	defer ts.Close()
+
+	// The first GET renders a form, creating the session, and uses up one of the three requests.
+	code, header, body := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("RateLimit-Limit"), "3")
+	assert.Equal(t, header.Get("RateLimit-Remaining"), "2")
+	csrfToken := extractCSRFToken(t, body)
+
+	// Sensitive routes have their own, stricter, limit on top of the site wide one.
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	code, header, body = ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("RateLimit-Limit"), "1")
+	assert.Equal(t, header.Get("Retry-After"), "1000")
+	assert.StringContains(t, body, "<title>Too Many Requests - Site</title>")
+
+	// Every request so far has counted towards the site wide limit.
+	code, header, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("RateLimit-Remaining"), "0")
+
+	// Limited requests still get the common headers, and are counted in the metrics.
+	assert.Equal(t, header.Get("X-Frame-Options"), "deny")
+	rr := httptest.NewRecorder()
+	app.metrics.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.StringContains(t, rr.Body.String(), `http_requests_total{route="unmatched",status="429"} 1`)
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.RateLimit.ByUser = true
+	app.rateLimiter = ratelimit.New(0.001, 1)
+	defer app.rateLimiter.Stop()
+
+	// Log the request in as the user given in the X-User-ID header, before it's rate limited.
+	handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, err := strconv.Atoi(r.Header.Get("X-User-ID")); err == nil {
+			app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+		}
+		app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})).ServeHTTP(w, r)
+	}))
+
+	tests := []struct {
+		name     string
+		userID   string
+		wantCode int
+	}{
+		{name: "User 1", userID: "1", wantCode: http.StatusTeapot},
+		{name: "User 1 again", userID: "1", wantCode: http.StatusTooManyRequests},
+		{name: "User 2 from the same IP", userID: "2", wantCode: http.StatusTeapot},
+		{name: "Anonymous from the same IP", wantCode: http.StatusTeapot},
+		{name: "Anonymous again", wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-User-ID", tt.userID)
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	app := newTestApplication(t)
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusTeapot)
+	assert.Equal(t, rr.Header().Get("RateLimit-Limit"), "")
+}
+
+func TestResolveClientIP(t *testing.T) {
+	app := newTestApplication(t)
+	trusted, err := realip.ParsePrefixes([]string{"10.0.0.0/8"})
+	assert.NilError(t, err)
+	app.realIP = realip.New(trusted)
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = app.clientIP(r)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:51234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	// Without the middleware, the connection's address is used.
+	next.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, got, "10.0.0.1")
+
+	app.resolveClientIP(next).ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, got, "198.51.100.1")
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{
+			name:     "Generated",
+			incoming: "",
+			wantSame: false,
+		},
+		{
+			name:     "Accepted from upstream",
+			incoming: "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+			wantSame: true,
+		},
+		{
+			name:     "Unsafe value replaced",
+			incoming: "abc\ninjected=true",
+			wantSame: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			var fromContext string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext, _ = requestid.FromContext(r.Context())
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+			app.requestID(next).ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, id, fromContext)
+			assert.Equal(t, requestid.Valid(id), true)
+			assert.Equal(t, id == tt.incoming, tt.wantSame)
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+		wantLog []string
+	}{
+		{
+			name: "Logged",
+			path: "/about",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				_, _ = w.Write([]byte("hello"))
+			},
+			wantLog: []string{"status=418", "size=5", "user_agent=test-agent", "referer=https://example.com/"},
+		},
+		{
+			name: "Implicit 200",
+			path: "/about",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hi"))
+			},
+			wantLog: []string{"status=200", "size=2"},
+		},
+		{
+			name: "Panic logged as 500",
+			path: "/about",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("oops")
+			},
+			wantLog: []string{"status=500"},
+		},
+		{
+			name: "Skipped path",
+			path: "/static/css/main.css",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("body {}"))
+			},
+			wantLog: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.Log.SkipPaths = []string{"/static/"}
+
+			var buf bytes.Buffer
+			app.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("User-Agent", "test-agent")
+			r.Header.Set("Referer", "https://example.com/")
+			rr := httptest.NewRecorder()
+			app.logRequest(app.sessionManager.LoadAndSave(app.recoverPanic(tt.handler))).ServeHTTP(rr, r)
+
+			logged := ""
+			for line := range strings.Lines(buf.String()) {
+				if strings.Contains(line, "Handled request") {
+					logged = line
+				}
+			}
+
+			if tt.wantLog == nil {
+				assert.Equal(t, logged, "")
+				return
+			}
+			for _, want := range tt.wantLog {
+				assert.StringContains(t, logged, want)
+			}
+		})
+	}
+}
+
+func TestResponseWriterUnwrap(t *testing.T) {
+	app := newTestApplication(t)
+
+	var flushErr error
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		flushErr = http.NewResponseController(w).Flush()
+	})
+
+	rr := httptest.NewRecorder()
+	app.logRequest(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NilError(t, flushErr)
+	assert.Equal(t, rr.Flushed, true)
+}
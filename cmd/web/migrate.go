@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rynhndrcksn/go-starter-site/internal/migrate"
+	"github.com/rynhndrcksn/go-starter-site/migrations"
+)
+
+var errMigrateUsage = errors.New("usage: web migrate up|down|status|version")
+
+// runMigrate handles the "migrate" subcommand, applying the embedded migrations to the database.
+func runMigrate(db *pgxpool.Pool, logger *slog.Logger, args []string) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+
+	migrator, err := migrate.New(db, logger, migrations.Files)
+	if err != nil {
+		return err
+	}
+
+	// Give the migrations plenty of time, but don't hang forever if the database stops responding.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Applied At\tMigration")
+		for _, status := range statuses {
+			appliedAt := "Pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d_%s.sql\n", appliedAt, status.Version, status.Name)
+		}
+		return tw.Flush()
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Version: %d\n", version)
+		return nil
+	default:
+		return errMigrateUsage
+	}
+}
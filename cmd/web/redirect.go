@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// redirectRoutes returns the handler for the plain HTTP listener, which redirects everything to HTTPS.
+// ACME HTTP-01 challenges are the exception, as they have to be answered over plain HTTP.
+func (app *application) redirectRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/acme-challenge/", app.acmeChallengeHandler())
+	mux.HandleFunc("/", app.redirectToHTTPSHandler)
+
+	return app.requestID(app.resolveClientIP(app.logRequest(app.recoverPanic(mux))))
+}
+
+// acmeChallengeHandler answers HTTP-01 challenges for the certificate manager when -acme-domains is set.
+// Otherwise, challenge files are served from the -tls-acme-webroot directory, which is what certbot --webroot expects.
+// The files are served from <webroot>/.well-known/acme-challenge/<token>, and directory listings are never shown.
+func (app *application) acmeChallengeHandler() http.Handler {
+	if app.certManager != nil {
+		return app.certManager.HTTPHandler(http.NotFoundHandler())
+	}
+
+	if app.config.TLS.ACMEWebroot == "" {
+		return http.NotFoundHandler()
+	}
+
+	files := http.FileServerFS(os.DirFS(app.config.TLS.ACMEWebroot))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// redirectToHTTPSHandler permanently redirects the request to the same host, path, and query over HTTPS.
+func (app *application) redirectToHTTPSHandler(w http.ResponseWriter, r *http.Request) {
+	// Without a Host header there's nowhere sensible to send the client, other than the configured base URL.
+	if r.Host == "" {
+		http.Redirect(w, r, strings.TrimSuffix(app.config.BaseURL, "/")+r.URL.RequestURI(), http.StatusMovedPermanently)
+		return
+	}
+
+	// Swap the port the request came in on for the one the HTTPS server listens on.
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	if app.config.Port == 443 {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else {
+		host = net.JoinHostPort(host, strconv.Itoa(app.config.Port))
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		host     string
+		target   string
+		wantCode int
+		wantLoc  string
+	}{
+		{
+			name:     "Default port",
+			port:     443,
+			host:     "example.com",
+			target:   "/user/login?next=%2Faccount",
+			wantCode: http.StatusMovedPermanently,
+			wantLoc:  "https://example.com/user/login?next=%2Faccount",
+		},
+		{
+			name:     "Custom port",
+			port:     4000,
+			host:     "localhost:8080",
+			target:   "/about",
+			wantCode: http.StatusMovedPermanently,
+			wantLoc:  "https://localhost:4000/about",
+		},
+		{
+			name:     "IPv6",
+			port:     443,
+			host:     "[::1]:80",
+			target:   "/",
+			wantCode: http.StatusMovedPermanently,
+			wantLoc:  "https://[::1]/",
+		},
+		{
+			name:     "Missing host",
+			port:     443,
+			host:     "",
+			target:   "/about",
+			wantCode: http.StatusMovedPermanently,
+			wantLoc:  "https://localhost:4000/about",
+		},
+		{
+			name:     "ACME challenge",
+			port:     443,
+			host:     "example.com",
+			target:   "/.well-known/acme-challenge/token",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "ACME challenge directory",
+			port:     443,
+			host:     "example.com",
+			target:   "/.well-known/acme-challenge/",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	webroot := t.TempDir()
+	challengeDir := filepath.Join(webroot, ".well-known", "acme-challenge")
+	assert.NilError(t, os.MkdirAll(challengeDir, 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(challengeDir, "token"), []byte("token.thumbprint"), 0o644))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.Port = tt.port
+			app.config.BaseURL = "https://localhost:4000"
+			app.config.TLS.ACMEWebroot = webroot
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = tt.host
+			rr := httptest.NewRecorder()
+			app.redirectRoutes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, rr.Header().Get("Location"), tt.wantLoc)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, rr.Body.String(), "token.thumbprint")
+			}
+		})
+	}
+}
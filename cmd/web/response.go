@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+)
+
+// responseWriter wraps a http.ResponseWriter, recording the status code and number of bytes written, for access logging.
+// Unwrap() lets http.ResponseController reach the underlying writer, so flushing and hijacking keep working.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// newResponseWriter wraps w. The status defaults to 200, which is what's sent if the handler never calls WriteHeader().
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	// Informational (1xx) responses can be followed by the real one, so they aren't recorded.
+	if !rw.wroteHeader && status >= 200 {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// watchRestart does nothing, as there's no SIGUSR2, and listeners can't be passed to a new process, on this platform.
+func (app *application) watchRestart() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchRestart restarts the site without refusing any connections whenever a SIGUSR2 is received, until the returned
+// function is called. A new copy of the process (i.e. a newly deployed binary) is started with the listeners, and once
+// it's ready, this process shuts down, finishing any requests it's already handling. If the new process fails to
+// start, this one carries on as if nothing happened.
+func (app *application) watchRestart() (stop func()) {
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-usr2:
+				app.logger.Info("restarting")
+				proc, err := app.listeners.Restart()
+				if err != nil {
+					app.logger.Error("restart failed", slog.String("error", err.Error()))
+					continue
+				}
+				app.lifecycle.Handover(fmt.Sprintf("restarted as process %d", proc.Pid))
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(usr2)
+		close(done)
+	}
+}
@@ -1,27 +1,49 @@
 package main
 
-import (
-	"expvar"
-	"net/http"
-
-	"github.com/rynhndrcksn/go-starter-site/ui"
-)
+import "net/http"
 
 // routes handles assigning all the routes for the site and what HTTP methods are used for them.
 func (app *application) routes() http.Handler {
 	// Initialize a new http.ServeMux instance.
 	mux := http.NewServeMux()
 
-	// Use the http.FileServerFS() function to create an HTTP handler which serves the embedded files in ui.Files.
-	// It's important to note that the static files are contained in the "static" folder of the ui.Files embedded filesystem.
+	// Use the http.FileServerFS() function to create an HTTP handler which serves the files in app.files.
+	// These are the embedded ui.Files, unless they're being read from disk in development (see devTemplatesDir()).
+	// It's important to note that the static files are contained in the "static" folder of the app.files filesystem.
 	// So, for example, our CSS stylesheet is located at "static/css/main.css".
-	mux.Handle("GET /static/", http.FileServerFS(ui.Files))
+	mux.Handle("GET /static/", http.FileServerFS(app.files))
 
 	// Register routes.
 	mux.HandleFunc("GET /", app.notFoundHandler)
 	mux.HandleFunc("GET /{$}", app.homeHandler)
 	mux.HandleFunc("GET /about", app.aboutHandler)
-	mux.Handle("GET /debug/vars", expvar.Handler())
 
-	return app.recoverPanic(app.logRequest(app.commonHeaders(mux)))
+	// Register user routes.
+	// Routes that are sensitive to brute forcing, or send emails, have stricter rate limits.
+	mux.HandleFunc("GET /user/signup", app.userSignupHandler)
+	mux.Handle("POST /user/signup", app.rateLimitAuth(http.HandlerFunc(app.userSignupPostHandler)))
+	mux.HandleFunc("GET /user/login", app.userLoginHandler)
+	mux.Handle("POST /user/login", app.rateLimitAuth(http.HandlerFunc(app.userLoginPostHandler)))
+	mux.HandleFunc("GET /user/password/forgot", app.userPasswordForgotHandler)
+	mux.Handle("POST /user/password/forgot", app.rateLimitAuth(http.HandlerFunc(app.userPasswordForgotPostHandler)))
+	mux.HandleFunc("GET /user/password/reset", app.userPasswordResetHandler)
+	mux.Handle("POST /user/password/reset", app.rateLimitAuth(http.HandlerFunc(app.userPasswordResetPostHandler)))
+	mux.Handle("POST /user/logout", app.requireAuthentication(http.HandlerFunc(app.userLogoutPostHandler)))
+	mux.HandleFunc("GET /user/verify", app.userVerifyHandler)
+	mux.HandleFunc("POST /user/verify", app.userVerifyPostHandler)
+	mux.Handle("POST /user/verify/resend", app.requireAuthentication(app.rateLimitAuth(http.HandlerFunc(app.userVerifyResendPostHandler))))
+	mux.Handle("GET /user/account", app.requireVerifiedUser(http.HandlerFunc(app.userAccountHandler)))
+
+	// Rate limiting happens before CSRF checks and authentication, so limited clients are turned away before doing any work
+	// with the session or database, but are still logged, counted, and sent the common headers.
+	site := app.requestID(app.resolveClientIP(app.recordMetrics(app.logRequest(app.recoverPanic(app.commonHeaders(app.rateLimit(app.preventCSRF(app.authenticate(app.routePattern(mux))))))))))
+
+	// Health checks are routed before the site's middleware, as load balancers poll them frequently.
+	// They don't need logging, sessions, or CSRF tokens, and mustn't be rate limited.
+	root := http.NewServeMux()
+	root.Handle("GET /healthz", app.health.LiveHandler())
+	root.Handle("GET /readyz", app.health.ReadyHandler(app.config.ReadyCache))
+	root.Handle("/", site)
+
+	return root
 }
@@ -1,19 +1,19 @@
 package main
 
 import (
-	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
 	"syscall"
 	"time"
+
+	"golang.org/x/crypto/acme"
 )
 
-// serve sets up a new http.Server and calls .ListenAndServe on it.
+// serve sets up a new http.Server and calls .Serve on it, or .ServeTLS if a certificate was configured.
+// Listeners handed over by a previous process (or systemd) are used instead of opening new ones, if there are any.
 func (app *application) serve() error {
 	// Initialize a tls.Config struct to hold any non-default TLS settings.
 	// In this case, change it to only use elliptic curves with assembly implementations that Go supports.
@@ -22,7 +22,7 @@ func (app *application) serve() error {
 	}
 	// Initialize HTTP server using some sensible timeout settings.
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Addr:         fmt.Sprintf(":%d", app.config.Port),
 		Handler:      app.sessionManager.LoadAndSave(app.routes()),
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 		IdleTimeout:  time.Minute,
@@ -31,58 +31,135 @@ func (app *application) serve() error {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	// Create a shutdownError channel used to receive any errors returned by the graceful Shutdown() function.
-	shutdownError := make(chan error)
+	// Serve TLS directly if a certificate was given, reloading it whenever it changes.
+	// Otherwise, if ACME is enabled, certificates are obtained and renewed automatically.
+	// acme.ALPNProto is needed to answer TLS-ALPN-01 challenges on the main listener.
+	useTLS := app.config.TLS.Cert != "" || app.certManager != nil
+	switch {
+	case app.certManager != nil:
+		tlsConfig.GetCertificate = app.certManager.GetCertificate
+		tlsConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	case app.config.TLS.Cert != "":
+		certs, err := newCertReloader(app.config.TLS.Cert, app.config.TLS.Key, app.logger)
+		if err != nil {
+			return err
+		}
+		tlsConfig.GetCertificate = certs.GetCertificate
 
-	// Start a background goroutine.
-	go func() {
-		// Create a quit channel which carries os.Signal values.
-		quit := make(chan os.Signal, 1)
+		stopWatching := certs.watchSignals()
+		defer stopWatching()
+	}
+
+	// Open the main listener first, so a port that's already in use (or needs privileges) is reported straight away.
+	ln, err := app.listeners.Listen("main", "tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
 
-		// Use signal.Notify() to listen for incoming SIGINT and SIGTERM signals and relay them to the quit channel.
-		// Any other signals will not be caught by signal.Notify() and will retain their default behavior.
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// Optionally redirect plain HTTP to HTTPS on a second server, which shares the same lifecycle as the main one.
+	var redirectSrv *http.Server
+	if app.config.TLS.RedirectPort != 0 {
+		redirectSrv = &http.Server{
+			Addr:         fmt.Sprintf(":%d", app.config.TLS.RedirectPort),
+			Handler:      app.redirectRoutes(),
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
 
-		// Read the signal from the quit channel.
-		// This code will block until a signal is received.
-		s := <-quit
+		ln, err := app.listeners.Listen("redirect", "tcp", redirectSrv.Addr)
+		if err != nil {
+			return err
+		}
 
-		// Log a message to say that the signal has been caught.
-		app.logger.Info("shutting down server", slog.String("signal", s.String()))
+		go func() {
+			app.logger.Info("starting redirect server", "addr", redirectSrv.Addr)
+			err := redirectSrv.Serve(ln)
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("redirect server stopped", slog.String("error", err.Error()))
+			}
+		}()
+	}
 
-		// Create a context with a 30-second timeout.
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	// Serve the operational endpoints on a separate listener, which also shares the same lifecycle as the main one.
+	// It isn't started without credentials, since it exposes things like the configuration and profiles.
+	var adminSrv *http.Server
+	if app.adminEnabled() {
+		// CPU profiles and traces take 30 seconds by default, so writes get longer than on the main server.
+		adminSrv = &http.Server{
+			Addr:         app.config.Admin.Addr,
+			Handler:      app.adminRoutes(),
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: time.Minute,
+		}
 
-		// Call Shutdown() on the server, passing in the context that was just made.
-		// Shutdown() will return nil if the graceful shutdown was successful, or an error (which may happen because of a
-		// problem closing the listeners, or because the shutdown didn't complete before the 30-second context deadline is hit).
-		// Relay this return value to the shutdownError channel.
-		err := srv.Shutdown(ctx)
+		ln, err := app.listeners.Listen("admin", "tcp", adminSrv.Addr)
 		if err != nil {
-			shutdownError <- err
+			return err
 		}
 
-		app.logger.Info("completing background tasks", slog.String("addr", srv.Addr))
+		go func() {
+			app.logger.Info("starting admin server", "addr", adminSrv.Addr)
+			err := adminSrv.Serve(ln)
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("admin server stopped", slog.String("error", err.Error()))
+			}
+		}()
+	} else if app.config.Admin.Addr != "" {
+		app.logger.Info("admin server disabled, as no credentials are set", slog.String("addr", app.config.Admin.Addr))
+	}
+
+	// Stop the servers before anything they depend on. Hooks run in reverse order, so the main server is stopped first.
+	// Calling Shutdown() stops them accepting new connections, then waits for active ones to finish.
+	if redirectSrv != nil {
+		app.lifecycle.OnShutdown("redirect server", redirectSrv.Shutdown)
+	}
+	if adminSrv != nil {
+		app.lifecycle.OnShutdown("admin server", adminSrv.Shutdown)
+	}
+	app.lifecycle.OnShutdown("server", srv.Shutdown)
 
-		// Call Wait() to block until the WaitGroup counter is zero, blocking until the background goroutines have finished.
-		// Then return nil on the shutdownError channel to indicate that the shutdown was completed without any issues.
-		app.wg.Wait()
-		shutdownError <- nil
+	// Start a background goroutine that waits for a SIGINT or SIGTERM, then shuts everything down.
+	// The buffer means the result can always be sent, even if the main server failed and nothing is receiving.
+	shutdownError := make(chan error, 1)
+	go func() {
+		shutdownError <- app.lifecycle.Wait(syscall.SIGINT, syscall.SIGTERM)
 	}()
 
-	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
+	// Hand the listeners over to a new process on SIGUSR2, so the site can be restarted without refusing connections.
+	stopWatching := app.watchRestart()
+	defer stopWatching()
+
+	// Close any handed over listeners for servers that are no longer enabled, then let the previous process know
+	// this one is ready, so it can shut down.
+	err = app.listeners.CloseUnused()
+	if err != nil {
+		return err
+	}
+	err = app.listeners.Ready()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.Env, "tls", useTLS)
 
-	// Calling Shutdown() on the server will cause ListenAndServe() to immediately return a http.ErrServerClosed error.
+	// Calling Shutdown() on the server will cause Serve() to immediately return a http.ErrServerClosed error.
 	// So if this error is seen, it is actually a good thing and a sign that the graceful shutdown has started.
 	// So check specifically for this, only returning the error if it is NOT http.ErrServerClosed.
-	err := srv.ListenAndServe()
+	// The certificate comes from tlsConfig.GetCertificate, so no files are passed to ServeTLS().
+	if useTLS {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
-	// Otherwise, wait to receive the return value from Shutdown() on the shutdownError channel.
-	// If return value is an error, there was a problem with the graceful shutdown, and return the error.
+	// Otherwise, wait for the rest of the shutdown hooks to finish, returning any errors they had.
 	err = <-shutdownError
 	if err != nil {
 		return err
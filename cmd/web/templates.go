@@ -8,12 +8,13 @@ import (
 	"html/template"
 	"io/fs"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/rynhndrcksn/go-starter-site/internal/env"
-	"github.com/rynhndrcksn/go-starter-site/ui"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/requestid"
 )
 
 var (
@@ -23,11 +24,16 @@ var (
 	errPropsKeyValueCountIsZero   = errors.New("length of 'pairs' must be greater than 0")
 )
 
-// functions contains a template.FuncMap that maps the above functions to functions that can then be called inside the templates.
-var functions = template.FuncMap{
-	"hashAssetPath": hashAssetPath,
-	"humanDate":     humanDate,
-	"props":         props,
+// templateFunctions returns a template.FuncMap that maps the functions below to names that can then be called inside the templates.
+// fsys is the filesystem hashAssetPath reads the assets from, which should be the same one the templates are parsed from.
+func templateFunctions(fsys fs.FS) template.FuncMap {
+	return template.FuncMap{
+		"hashAssetPath": func(originalPath string) (string, error) {
+			return hashAssetPath(fsys, originalPath)
+		},
+		"humanDate": humanDate,
+		"props":     props,
+	}
 }
 
 // hashAssetPath takes an asset path, computes the hash for the asset, appends it to the asset name, and returns it.
@@ -36,13 +42,13 @@ var functions = template.FuncMap{
 // This works by appending a ?v=<hash> to the end of the file, so it isn't incredibly robust for advanced needs.
 // However, the <hash> is 32 characters long, so it's sufficient for most needs.
 // Partially inspired by https://github.com/c9845/hashfs
-func hashAssetPath(originalPath string) (string, error) {
+func hashAssetPath(fsys fs.FS, originalPath string) (string, error) {
 	if len(strings.TrimSpace(originalPath)) == 0 {
 		return "", errHashAssetPathIsEmpty
 	}
 
 	// Strip off the first "/", so the following fs.ReadFile line succeeds.
-	contents, err := fs.ReadFile(ui.Files, originalPath[1:])
+	contents, err := fs.ReadFile(fsys, originalPath[1:])
 	if err != nil {
 		return originalPath, errHashAssetCantReadFile
 	}
@@ -89,35 +95,58 @@ func props(pairs ...any) (map[string]any, error) {
 
 // templateData holds dynamic data that can be passed to the HTML templates.
 type templateData struct {
-	CanonicalUrl string
-	CurrentYear  int
-	Description  string
-	Flash        string
-	ImageUrl     string
-	PageType     string
-	SiteName     string
-	Title        string
+	CanonicalUrl    string
+	CurrentYear     int
+	Description     string
+	Flash           string
+	Form            any
+	ImageUrl        string
+	IsAuthenticated bool
+	PageType        string
+	RequestID       string
+	SiteName        string
+	Title           string
+	User            data.User
+
+	// csrfToken is called by CSRFToken(), so the token is only created when a template needs it.
+	csrfToken func() (string, error)
+}
+
+// CSRFToken returns the session's CSRF token, for forms to send back in a "csrf_token" field.
+// The token is created the first time a form is rendered for the session, rather than for every request.
+func (td templateData) CSRFToken() (string, error) {
+	if td.csrfToken == nil {
+		return "", nil
+	}
+	return td.csrfToken()
 }
 
 // newTemplateData initializes a new templateData struct and returns it.
 func (app *application) newTemplateData(r *http.Request) templateData {
+	requestID, _ := requestid.FromContext(r.Context())
+
 	return templateData{
-		CanonicalUrl: getCanonicalURL(r),
-		CurrentYear:  time.Now().Year(),
-		Flash:        app.sessionManager.PopString(r.Context(), "flash"),
-		SiteName:     env.GetStringOrDefault("SITE_NAME", "Site"),
+		CanonicalUrl:    getCanonicalURL(r),
+		CurrentYear:     time.Now().Year(),
+		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated: app.isAuthenticated(r),
+		RequestID:       requestID,
+		SiteName:        app.config.SiteName,
+		csrfToken: func() (string, error) {
+			return app.csrfToken(r)
+		},
 	}
 }
 
-// newTemplateCache grabs all the templates in ui/html/, renders them, and adds them to a map.
+// newTemplateCache grabs all the templates in the html/ directory of fsys, renders them, and adds them to a map.
 // This way the template doesn't have to be rendered on every request.
-func newTemplateCache() (map[string]*template.Template, error) {
+func newTemplateCache(fsys fs.FS) (map[string]*template.Template, error) {
 	// Initialize a new map to act as the cache.
 	cache := map[string]*template.Template{}
 
-	// Use fs.Glob() to get a slice of all file paths in the ui.Files embedded filesystem which match the pattern 'html/pages/*.tmpl'.
+	// Use fs.Glob() to get a slice of all file paths in the fsys filesystem which match the pattern 'html/pages/*.tmpl'.
 	// This gives us a slice of all the 'page' templates for the application.
-	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	pages, err := fs.Glob(fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -127,17 +156,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// Extract the file name (like 'home.tmpl') from the full filepath and assign it to the new variable.
 		name := filepath.Base(page)
 
-		// Create a slice containing the filepath patterns for the templates we want to parse.
-		patterns := []string{
-			"html/base.tmpl",
-			"html/partials/*.tmpl",
-			"html/components/*.tmpl",
-			page,
-		}
-
-		// Use ParseFS() to parse the template files from the ui.Files embedded filesystem.
-		var ts *template.Template
-		ts, err = template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		ts, err := parseTemplate(fsys, name)
 		if err != nil {
 			return nil, err
 		}
@@ -149,6 +168,41 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	return cache, nil
 }
 
+// parseTemplate parses the page called name (like 'home.tmpl') from fsys, along with the base, partials, and components it uses.
+func parseTemplate(fsys fs.FS, name string) (*template.Template, error) {
+	// Create a slice containing the filepath patterns for the templates we want to parse.
+	patterns := []string{
+		"html/base.tmpl",
+		"html/partials/*.tmpl",
+		"html/components/*.tmpl",
+		"html/pages/" + name,
+	}
+
+	// Use ParseFS() to parse the template files from the fsys filesystem.
+	return template.New(name).Funcs(templateFunctions(fsys)).ParseFS(fsys, patterns...)
+}
+
+// devTemplatesDir returns the directory to read the templates and static files from, instead of the embedded copies,
+// or "" if the embedded copies should be used. This is -dev-templates if it's set, otherwise "ui" in development,
+// as long as it exists (i.e. when the site is run from the root of the repository, but not from the Docker image).
+func devTemplatesDir(conf config) (string, error) {
+	if conf.DevTemplates != "" {
+		info, err := os.Stat(filepath.Join(conf.DevTemplates, "html"))
+		if err != nil || !info.IsDir() {
+			return "", fmt.Errorf("-dev-templates %q doesn't contain an html directory", conf.DevTemplates)
+		}
+		return conf.DevTemplates, nil
+	}
+
+	if conf.Env == "development" {
+		info, err := os.Stat(filepath.Join("ui", "html"))
+		if err == nil && info.IsDir() {
+			return "ui", nil
+		}
+	}
+	return "", nil
+}
+
 // getCanonicalURL creates the canonical URL and returns it.
 func getCanonicalURL(r *http.Request) string {
 	// Get the full URL from the request
@@ -3,22 +3,20 @@ package main
 import (
 	"errors"
 	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/internal/mailer"
 	"github.com/rynhndrcksn/go-starter-site/internal/testdata"
 	"github.com/rynhndrcksn/go-starter-site/ui"
 )
 
 func TestHashAssetPath(t *testing.T) {
-	// Temporarily replace the global ui.Files with the test files until after the test is done.
-	originalFiles := ui.Files
-	ui.Files = testdata.TestFiles
-	defer func() {
-		ui.Files = originalFiles
-	}()
-
 	tests := []struct {
 		name    string
 		input   string
@@ -53,7 +51,7 @@ func TestHashAssetPath(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := hashAssetPath(tt.input)
+			got, err := hashAssetPath(testdata.TestFiles, tt.input)
 
 			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("got: %v, want: %v", err, tt.wantErr)
@@ -67,8 +65,8 @@ func TestHashAssetPath(t *testing.T) {
 	}
 
 	path := "/assets/test.txt"
-	hash1, _ := hashAssetPath(path)
-	hash2, _ := hashAssetPath(path)
+	hash1, _ := hashAssetPath(testdata.TestFiles, path)
+	hash2, _ := hashAssetPath(testdata.TestFiles, path)
 
 	t.Run("consistent hashing", func(t *testing.T) {
 		if hash1 != hash2 {
@@ -78,14 +76,8 @@ func TestHashAssetPath(t *testing.T) {
 }
 
 func BenchmarkHashAssetPath(b *testing.B) {
-	// Temporarily replace the global ui.Files with the test files until after the test is done.
-	originalFiles := ui.Files
-	ui.Files = testdata.TestFiles
-	defer func() {
-		ui.Files = originalFiles
-	}()
 	for i := 0; i < b.N; i++ {
-		_, _ = hashAssetPath("/assets/test.txt")
+		_, _ = hashAssetPath(testdata.TestFiles, "/assets/test.txt")
 	}
 }
 
@@ -169,3 +161,139 @@ func BenchmarkProps(b *testing.B) {
 		_, _ = props([]any{"key1", "value1", "key2", 5, "key3", true})
 	}
 }
+
+func TestEmailTemplates(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateFile string
+		data         map[string]any
+	}{
+		{
+			name:         "Welcome email",
+			templateFile: "welcome.tmpl",
+			data:         map[string]any{"name": "Bob", "siteName": "Site", "verifyURL": "https://localhost:4000/user/verify?token=abc"},
+		},
+		{
+			name:         "Verify email",
+			templateFile: "verify-email.tmpl",
+			data:         map[string]any{"name": "Bob", "siteName": "Site", "verifyURL": "https://localhost:4000/user/verify?token=abc"},
+		},
+		{
+			name:         "Password reset email",
+			templateFile: "password-reset.tmpl",
+			data:         map[string]any{"name": "Bob", "resetURL": "https://localhost:4000/user/password/reset?token=abc", "siteName": "Site"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &mailer.Recorder{}
+			m := mailer.New(recorder, "Site <no-reply@example.com>", ui.Files, templateFunctions(ui.Files))
+
+			err := m.Send("bob@example.com", tt.templateFile, tt.data)
+			assert.NilError(t, err)
+			assert.Equal(t, len(recorder.Emails()), 1)
+		})
+	}
+}
+
+func TestDevTemplatesDir(t *testing.T) {
+	// Run from a directory laid out like the root of the repository, with the templates in ui/html.
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "ui", "html"), 0o755)
+	assert.NilError(t, err)
+	t.Chdir(root)
+
+	tests := []struct {
+		name         string
+		env          string
+		devTemplates string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name: "Development",
+			env:  "development",
+			want: "ui",
+		},
+		{
+			name: "Production",
+			env:  "production",
+			want: "",
+		},
+		{
+			name:         "Configured directory",
+			env:          "production",
+			devTemplates: "./ui",
+			want:         "./ui",
+		},
+		{
+			name:         "Directory without templates",
+			env:          "development",
+			devTemplates: root,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conf config
+			conf.Env = tt.env
+			conf.DevTemplates = tt.devTemplates
+
+			got, err := devTemplatesDir(conf)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+
+	// Development without a ui directory (i.e. in the Docker image) uses the embedded files.
+	t.Chdir(t.TempDir())
+	got, err := devTemplatesDir(config{Env: "development"})
+	assert.NilError(t, err)
+	assert.Equal(t, got, "")
+}
+
+func TestRenderDevTemplates(t *testing.T) {
+	app := newTestApplication(t)
+	app.devTemplates = true
+
+	dir := t.TempDir()
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		assert.NilError(t, err)
+		err = os.WriteFile(path, []byte(contents), 0o644)
+		assert.NilError(t, err)
+	}
+	writeFile("html/base.tmpl", `{{define "base"}}<title>{{.Title}}</title>{{template "main" .}}{{end}}`)
+	writeFile("html/partials/nav.tmpl", `{{define "nav"}}{{end}}`)
+	writeFile("html/components/button.tmpl", `{{define "button"}}{{end}}`)
+	writeFile("html/pages/home.tmpl", `{{define "main"}}<p>Before</p>{{end}}`)
+
+	// Read the templates from the directory, like -dev-templates does.
+	app.files = os.DirFS(dir)
+
+	render := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		app.render(rr, r, http.StatusOK, "home.tmpl", templateData{Title: "Home"})
+		return rr
+	}
+
+	rr := render()
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.StringContains(t, rr.Body.String(), "<p>Before</p>")
+
+	// Changes show up on the next request, without the template cache being rebuilt.
+	writeFile("html/pages/home.tmpl", `{{define "main"}}<p>After</p>{{end}}`)
+	rr = render()
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.StringContains(t, rr.Body.String(), "<p>After</p>")
+
+	// A mistake in a template is shown, rather than rendering the error page from the same broken templates.
+	writeFile("html/base.tmpl", `{{define "base"}}{{.Title}`)
+	rr = render()
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.StringContains(t, rr.Body.String(), "base.tmpl")
+}
@@ -2,22 +2,44 @@ package main
 
 import (
 	"bytes"
+	"html"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+	"github.com/rynhndrcksn/go-starter-site/internal/health"
+	"github.com/rynhndrcksn/go-starter-site/internal/jobs"
+	"github.com/rynhndrcksn/go-starter-site/internal/mailer"
+	"github.com/rynhndrcksn/go-starter-site/ui"
 )
 
+// csrfTokenRX captures the CSRF token from the hidden form field rendered in the templates.
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
+
+// extractCSRFToken returns the CSRF token from a rendered HTML body.
+func extractCSRFToken(t *testing.T, body string) string {
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
+
 // newTestApplication creates a new application struct containing mocked dependencies.
 func newTestApplication(t *testing.T) *application {
 	// Initialize a new template cache.
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(ui.Files)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -31,13 +53,65 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.HashTokenInStore = true
 	sessionManager.Store = memstore.New()
 
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
 	return &application{
-		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config:         config{SiteName: "Site"},
+		files:          ui.Files,
+		health:         health.New(),
+		jobs:           jobs.New(&testJobStore{}, logger, jobs.Config{}),
+		logger:         logger,
+		logLevel:       new(slog.LevelVar),
+		mailer:         mailer.New(&mailer.Recorder{}, "Site <no-reply@example.com>", ui.Files, templateFunctions(ui.Files)),
+		metrics:        newSiteMetrics(nil),
 		templateCache:  templateCache,
 		sessionManager: sessionManager,
 	}
 }
 
+// testJobStore is a jobs.Store that records enqueued jobs instead of storing them in a database.
+// The queue is never started in tests, so only Insert needs to do anything.
+type testJobStore struct {
+	mu   sync.Mutex
+	jobs []data.Job
+}
+
+func (s *testJobStore) Insert(name string, payload []byte, maxAttempts int, runAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, data.Job{ID: len(s.jobs) + 1, Name: name, Payload: payload, MaxAttempts: maxAttempts, RunAt: runAt})
+	return len(s.jobs), nil
+}
+
+func (s *testJobStore) Claim(time.Duration) (data.Job, error) {
+	return data.Job{}, data.ErrRecordNotFound
+}
+
+func (s *testJobStore) Complete(int) error {
+	return nil
+}
+
+func (s *testJobStore) Retry(int, time.Time, string) error {
+	return nil
+}
+
+func (s *testJobStore) Kill(int, string) error {
+	return nil
+}
+
+func (s *testJobStore) DeleteFinished(time.Time) (int64, error) {
+	return 0, nil
+}
+
+// enqueued returns a copy of every job enqueued so far.
+func (s *testJobStore) enqueued() []data.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]data.Job(nil), s.jobs...)
+}
+
 // testServer embeds a *httptest.Server instance.
 type testServer struct {
 	*httptest.Server
@@ -91,6 +165,29 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+// postForm will make a POST request to a given URL path using the test server client, with the given form data
+// as the request body, and returns the response status code, response headers, and response body.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(rs.Body)
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
 func (app *application) routeThatPanics() http.Handler {
 	// Initialize a new http.ServeMux instance.
 	mux := http.NewServeMux()
@@ -100,5 +197,5 @@ func (app *application) routeThatPanics() http.Handler {
 		panic("this triggers a 500 status page")
 	})
 
-	return app.recoverPanic(mux)
+	return app.requestID(app.recoverPanic(mux))
 }
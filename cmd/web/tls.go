@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes, at most.
+// Checks happen during TLS handshakes, so an idle server doesn't touch the disk at all.
+const certCheckInterval = 10 * time.Second
+
+// certReloader serves a TLS key pair loaded from disk, reloading it when the files change, or a SIGHUP is received.
+// This means certificates can be rotated (i.e. by certbot) without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader returns a certReloader with the key pair already loaded, so any problems are caught at startup.
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+
+	err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// reload loads the key pair from disk.
+// If this fails, the previously loaded key pair continues to be served.
+func (cr *certReloader) reload() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.checkedAt = time.Now()
+	cr.mu.Unlock()
+
+	cr.logger.Info("loaded TLS certificate", slog.String("cert", cr.certFile), slog.Time("expires", cert.Leaf.NotAfter))
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the certificate and key files.
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate is used as the tls.Config.GetCertificate callback.
+// It checks whether the files have changed if it's been long enough since the last check, and reloads them if so.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	stale := time.Since(cr.checkedAt) >= certCheckInterval
+	if stale {
+		cr.checkedAt = time.Now()
+	}
+	modTime := cr.modTime
+	cr.mu.Unlock()
+
+	if stale {
+		latest, err := cr.latestModTime()
+		if err == nil && !latest.Equal(modTime) {
+			err = cr.reload()
+		}
+		if err != nil {
+			cr.logger.Error("reloading TLS certificate", slog.String("error", err.Error()))
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.cert, nil
+}
+
+// watchSignals reloads the key pair whenever a SIGHUP is received, until the returned function is called.
+func (cr *certReloader) watchSignals() (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				err := cr.reload()
+				if err != nil {
+					cr.logger.Error("reloading TLS certificate", slog.String("error", err.Error()))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
+
+// usesTLS reports whether the site serves HTTPS with conf, including using the development certificate.
+func usesTLS(conf config) bool {
+	return conf.TLS.Cert != "" || len(conf.ACME.Domains) > 0 || useDevcert(conf)
+}
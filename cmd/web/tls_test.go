@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+// writeTestCert writes a self-signed certificate for commonName, and its key, into dir.
+func writeTestCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "one.example.com")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cr, err := newCertReloader(certFile, keyFile, logger)
+	assert.NilError(t, err)
+
+	cert, err := cr.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, cert.Leaf.Subject.CommonName, "one.example.com")
+
+	// Rotate the certificate, making sure the modification time changes.
+	writeTestCert(t, dir, "two.example.com")
+	future := time.Now().Add(time.Minute)
+	assert.NilError(t, os.Chtimes(certFile, future, future))
+
+	// The files shouldn't be checked again until certCheckInterval has passed.
+	cert, _ = cr.GetCertificate(nil)
+	assert.Equal(t, cert.Leaf.Subject.CommonName, "one.example.com")
+
+	cr.mu.Lock()
+	cr.checkedAt = time.Now().Add(-certCheckInterval)
+	cr.mu.Unlock()
+
+	cert, _ = cr.GetCertificate(nil)
+	assert.Equal(t, cert.Leaf.Subject.CommonName, "two.example.com")
+
+	// A broken key pair should be reported, while the old one keeps being served.
+	err = os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	assert.NilError(t, err)
+	assert.Equal(t, cr.reload() != nil, true)
+
+	cert, _ = cr.GetCertificate(nil)
+	assert.Equal(t, cert.Leaf.Subject.CommonName, "two.example.com")
+}
+
+func TestNewCertReloaderMissingFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := newCertReloader("missing.pem", "missing-key.pem", logger)
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ACMECacheModel wraps a database connection pool and handles storing/retrieving ACME account keys and certificates.
+// Keeping them in the database means every instance of the site shares the same certificates,
+// rather than each one requesting its own and running into rate limits.
+type ACMECacheModel struct {
+	DB *pgxpool.Pool
+}
+
+// Get returns the data stored under key.
+// If there's nothing stored under key, ErrRecordNotFound is returned.
+func (m ACMECacheModel) Get(key string) ([]byte, error) {
+	var data []byte
+
+	query := `
+		SELECT data
+		FROM acme_cache
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, key).Scan(&data)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Put stores data under key, replacing anything that was already there.
+func (m ACMECacheModel) Put(key string, data []byte) error {
+	query := `
+		INSERT INTO acme_cache (key, data)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data, updated_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, key, data)
+	return err
+}
+
+// Delete removes the data stored under key, if there is any.
+func (m ACMECacheModel) Delete(key string) error {
+	query := `
+		DELETE FROM acme_cache
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, key)
+	return err
+}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The states a job can be in.
+// Jobs start out pending, are running while a worker has them, and end up either completed or dead.
+// Dead jobs have used up all of their attempts, and are kept around so they can be inspected.
+const (
+	JobStatePending   = "pending"
+	JobStateRunning   = "running"
+	JobStateCompleted = "completed"
+	JobStateDead      = "dead"
+)
+
+// Job represents a single row in the jobs table.
+type Job struct {
+	ID          int
+	Name        string
+	Payload     []byte
+	State       string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// JobModel wraps a database connection pool and handles storing/retrieving background jobs.
+type JobModel struct {
+	DB *pgxpool.Pool
+}
+
+// Insert adds a new pending job to the jobs table, which will be run once runAt has passed.
+func (m JobModel) Insert(name string, payload []byte, maxAttempts int, runAt time.Time) (int, error) {
+	var id int
+
+	query := `
+		INSERT INTO jobs (name, payload, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, name, payload, maxAttempts, runAt).Scan(&id)
+	return id, err
+}
+
+// Claim marks the next job that's due as running, increments its attempts, and returns it.
+// Jobs that have been running for longer than lockTimeout are assumed to belong to a worker that died, and can be claimed again.
+// FOR UPDATE SKIP LOCKED means multiple workers (and processes) can claim jobs at the same time without getting the same one.
+// If there are no jobs due, ErrRecordNotFound is returned.
+func (m JobModel) Claim(lockTimeout time.Duration) (Job, error) {
+	var job Job
+
+	query := `
+		UPDATE jobs
+		SET state = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE (state = 'pending' AND run_at <= NOW())
+			OR (state = 'running' AND locked_at < NOW() - $1::INTERVAL)
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, name, payload, state, attempts, max_attempts, run_at, last_error, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, lockTimeout).Scan(&job.ID, &job.Name, &job.Payload, &job.State, &job.Attempts,
+		&job.MaxAttempts, &job.RunAt, &job.LastError, &job.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Job{}, ErrRecordNotFound
+		}
+		return Job{}, err
+	}
+
+	return job, nil
+}
+
+// Complete marks the job as successfully completed.
+func (m JobModel) Complete(id int) error {
+	query := `
+		UPDATE jobs
+		SET state = 'completed', locked_at = NULL, last_error = '', updated_at = NOW()
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, id)
+	return err
+}
+
+// Retry puts a failed job back into the pending state, so it's run again once runAt has passed.
+func (m JobModel) Retry(id int, runAt time.Time, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET state = 'pending', run_at = $2, locked_at = NULL, last_error = $3, updated_at = NOW()
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, id, runAt, lastError)
+	return err
+}
+
+// DeleteFinished deletes completed and dead jobs that finished before the given time, returning how many were deleted.
+// Finished jobs are only kept for a while, as their payloads can contain personal details, such as email addresses.
+func (m JobModel) DeleteFinished(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM jobs
+		WHERE state IN ('completed', 'dead') AND updated_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tag, err := m.DB.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Kill moves a job that won't be retried into the dead state.
+func (m JobModel) Kill(id int, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET state = 'dead', locked_at = NULL, last_error = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, id, lastError)
+	return err
+}
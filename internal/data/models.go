@@ -1,16 +1,32 @@
 package data
 
 import (
+	"errors"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrDuplicateEmail        = errors.New("duplicate email")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrRecordNotFound        = errors.New("record not found")
+	ErrVerificationThrottled = errors.New("verification email sent too recently")
+)
+
 // Models struct contain the other models our application needs.
-// For example: Users UserModel
 type Models struct {
+	ACMECache ACMECacheModel
+	Jobs      JobModel
+	Tokens    TokenModel
+	Users     UserModel
 }
 
 // NewModels returns a new Models struct.
-// For example: Users: UserModel{DB:db}
 func NewModels(db *pgxpool.Pool) Models {
-	return Models{}
+	return Models{
+		ACMECache: ACMECacheModel{DB: db},
+		Jobs:      JobModel{DB: db},
+		Tokens:    TokenModel{DB: db},
+		Users:     UserModel{DB: db},
+	}
 }
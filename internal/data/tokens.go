@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// ScopePasswordReset is the scope for tokens emailed to users that forgot their password.
+	ScopePasswordReset = "password-reset"
+	// ScopeVerification is the scope for tokens emailed to users to verify their email address.
+	ScopeVerification = "verification"
+)
+
+// GeneratePlaintext returns a new random plaintext token, to be stored with InsertPlaintext() and sent to the user.
+// Only the SHA-256 hash of the token is stored in the database. Generating it separately means it's known before it's stored,
+// so a job that's retried can store and send the same token every time.
+func GeneratePlaintext() (string, error) {
+	// Fill a byte slice with 16 random bytes from the operating system's CSPRNG.
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// Encode the bytes to a base-32 string without padding, which results in a 26 character string like "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU".
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// hashToken returns the SHA-256 hash of a plaintext token.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// TokenModel wraps a database connection pool and handles storing/retrieving tokens.
+type TokenModel struct {
+	DB *pgxpool.Pool
+}
+
+// InsertPlaintext stores the plaintext token for the user, unless it's already been stored.
+// Calling it again with the same plaintext (i.e. when a job is retried) doesn't create another token.
+func (m TokenModel) InsertPlaintext(plaintext string, userID int, expiry time.Time, scope string) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (hash) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, hashToken(plaintext), userID, expiry, scope)
+	return err
+}
+
+// Consume deletes the matching, unexpired token for the scope and returns the ID of the user it belonged to.
+// Since the token is deleted in the same query that checks it, a token can only ever be consumed once.
+// If no matching token is found, ErrRecordNotFound is returned.
+func (m TokenModel) Consume(scope, tokenPlaintext string) (int, error) {
+	var userID int
+
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expiry > NOW()
+		RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, hashToken(tokenPlaintext), scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// DeleteScopeForUser deletes every token with the given scope belonging to the user.
+func (m TokenModel) DeleteScopeForUser(scope string, userID int) error {
+	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctx, query, scope, userID)
+	return err
+}
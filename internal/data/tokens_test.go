@@ -0,0 +1,19 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestGeneratePlaintext(t *testing.T) {
+	plaintext, err := GeneratePlaintext()
+	assert.NilError(t, err)
+	assert.Equal(t, len(plaintext), 26)
+	assert.Equal(t, len(hashToken(plaintext)), 32)
+
+	// Two tokens should never be the same.
+	other, err := GeneratePlaintext()
+	assert.NilError(t, err)
+	assert.Equal(t, plaintext == other, false)
+}
@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost is the cost used when hashing passwords.
+// 12 is a reasonable balance between security and the time it takes to hash a password.
+const bcryptCost = 12
+
+// User represents a single row in the users table.
+type User struct {
+	ID             int
+	Name           string
+	Email          string
+	HashedPassword []byte
+	CreatedAt      time.Time
+	VerifiedAt     *time.Time
+}
+
+// IsVerified returns true if the user has verified their email address.
+func (u User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// UserModel wraps a database connection pool and handles storing/retrieving users.
+type UserModel struct {
+	DB *pgxpool.Pool
+}
+
+// Insert hashes the plaintext password, adds a new user to the users table, and returns the new user's ID.
+// Since a verification email is sent on signup, the verification_sent_at column is set as well.
+// If the email address is already in use, ErrDuplicateEmail is returned.
+func (m UserModel) Insert(name, email, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	query := `
+		INSERT INTO users (name, email, hashed_password, verification_sent_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id`
+
+	// Create a context with a 3-second timeout deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRow(ctx, query, name, normalizeEmail(email), hashedPassword).Scan(&id)
+	if err != nil {
+		// If the unique constraint on the email column is violated, return ErrDuplicateEmail instead.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_email_key" {
+			return 0, ErrDuplicateEmail
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Authenticate checks whether a user exists with the provided email address and password.
+// If they do, the user's ID is returned, otherwise ErrInvalidCredentials is returned.
+func (m UserModel) Authenticate(email, password string) (int, error) {
+	var (
+		id             int
+		hashedPassword []byte
+	)
+
+	query := `
+		SELECT id, hashed_password
+		FROM users
+		WHERE email = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, normalizeEmail(email)).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	// Check whether the hashed password and plaintext password provided match.
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Get returns the user with the given ID.
+// If no matching user is found, ErrRecordNotFound is returned.
+func (m UserModel) Get(id int) (User, error) {
+	var user User
+
+	query := `
+		SELECT id, name, email, hashed_password, created_at, verified_at
+		FROM users
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.HashedPassword, &user.CreatedAt, &user.VerifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrRecordNotFound
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// GetByEmail returns the user with the given email address.
+// If no matching user is found, ErrRecordNotFound is returned.
+func (m UserModel) GetByEmail(email string) (User, error) {
+	var user User
+
+	query := `
+		SELECT id, name, email, hashed_password, created_at, verified_at
+		FROM users
+		WHERE email = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, normalizeEmail(email)).Scan(&user.ID, &user.Name, &user.Email, &user.HashedPassword, &user.CreatedAt, &user.VerifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrRecordNotFound
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// GetForToken returns the user that owns the unexpired token with the given scope, without consuming the token.
+// If no matching user is found, ErrRecordNotFound is returned.
+func (m UserModel) GetForToken(scope, tokenPlaintext string) (User, error) {
+	var user User
+
+	query := `
+		SELECT users.id, users.name, users.email, users.hashed_password, users.created_at, users.verified_at
+		FROM users
+		INNER JOIN tokens ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctx, query, hashToken(tokenPlaintext), scope).Scan(&user.ID, &user.Name, &user.Email, &user.HashedPassword, &user.CreatedAt, &user.VerifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrRecordNotFound
+		}
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// ResetPassword consumes the password reset token, stores the new password for the user it belonged to, and deletes the
+// user's other tokens, returning the user's ID. It all happens in a single transaction, so if anything fails, the token can
+// still be used again. If no matching, unexpired token is found, ErrRecordNotFound is returned.
+func (m UserModel) ResetPassword(tokenPlaintext, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int
+	err = pgx.BeginFunc(ctx, m.DB, func(tx pgx.Tx) error {
+		query := `
+			DELETE FROM tokens
+			WHERE hash = $1 AND scope = $2 AND expiry > NOW()
+			RETURNING user_id`
+
+		err := tx.QueryRow(ctx, query, hashToken(tokenPlaintext), ScopePasswordReset).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE users SET hashed_password = $1 WHERE id = $2`, hashedPassword, userID)
+		if err != nil {
+			return err
+		}
+
+		// Delete any other tokens the user has, in case someone else had access to their email.
+		_, err = tx.Exec(ctx, `DELETE FROM tokens WHERE user_id = $1`, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Verify marks the user with the given ID as having verified their email address.
+// Users that are already verified keep their original verification time.
+func (m UserModel) Verify(id int) error {
+	query := `UPDATE users SET verified_at = COALESCE(verified_at, NOW()) WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// MarkVerificationSent records that a verification email is being sent to the unverified user with the given ID.
+// If one was already sent within the throttle duration, ErrVerificationThrottled is returned, and nothing is updated.
+// Checking and updating happens in a single query, so concurrent requests can't both get through.
+func (m UserModel) MarkVerificationSent(id int, throttle time.Duration) error {
+	query := `
+		UPDATE users
+		SET verification_sent_at = NOW()
+		WHERE id = $1
+		AND verified_at IS NULL
+		AND (verification_sent_at IS NULL OR verification_sent_at < NOW() - $2::INTERVAL)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctx, query, id, throttle)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrVerificationThrottled
+	}
+
+	return nil
+}
+
+// normalizeEmail lowercases and trims an email address so lookups aren't case-sensitive.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,254 @@
+// Package devcert generates a local certificate authority, and a certificate for localhost signed by it.
+// This lets the site be served over HTTPS during development, so secure cookies behave the same as they do in production.
+package devcert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+
+	// renewWithin is how close to expiring the localhost certificate can get before a new one is generated.
+	renewWithin = 30 * 24 * time.Hour
+)
+
+// Hosts are the names the localhost certificate is valid for.
+var Hosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// Files contains the paths to the generated files.
+// CACert is what needs to be trusted by browsers, Cert and Key are what the server uses.
+type Files struct {
+	CACert string
+	CAKey  string
+	Cert   string
+	Key    string
+}
+
+// DefaultDir returns the directory the certificates are cached in by default, inside the user's cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-starter-site", "devcert"), nil
+}
+
+// Ensure makes sure dir contains a CA and a valid localhost certificate signed by it, generating whichever are missing.
+// The CA is kept for as long as possible, so it only needs to be trusted once.
+// The localhost certificate is regenerated when it's close to expiring, or no longer matches the CA.
+// The returned bool reports whether anything was generated.
+func Ensure(dir string) (Files, bool, error) {
+	files := Files{
+		CACert: filepath.Join(dir, "ca.pem"),
+		CAKey:  filepath.Join(dir, "ca-key.pem"),
+		Cert:   filepath.Join(dir, "localhost.pem"),
+		Key:    filepath.Join(dir, "localhost-key.pem"),
+	}
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return Files{}, false, err
+	}
+
+	generated := false
+	ca, caKey, err := loadKeyPair(files.CACert, files.CAKey)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Files{}, false, err
+	}
+	// A CA without name constraints could sign a certificate for any site, so it's regenerated too.
+	if err != nil || !constrained(ca) {
+		ca, caKey, err = createCA(files)
+		if err != nil {
+			return Files{}, false, err
+		}
+		generated = true
+	}
+
+	cert, _, err := loadKeyPair(files.Cert, files.Key)
+	if err == nil && !generated && valid(cert, ca) {
+		return files, false, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Files{}, false, err
+	}
+
+	err = createCert(files, ca, caKey)
+	if err != nil {
+		return Files{}, false, err
+	}
+	return files, true, nil
+}
+
+// valid reports whether cert is signed by ca, covers every host, and isn't close to expiring.
+func valid(cert, ca *x509.Certificate) bool {
+	if time.Until(cert.NotAfter) < renewWithin {
+		return false
+	}
+	if cert.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+	for _, host := range Hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// constrained reports whether ca can only sign certificates for Hosts.
+func constrained(ca *x509.Certificate) bool {
+	return ca.PermittedDNSDomainsCritical && len(ca.PermittedDNSDomains) > 0 && len(ca.PermittedIPRanges) > 0
+}
+
+// createCA generates a new CA, and writes it to disk.
+func createCA(files Files) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-starter-site development CA"}, CommonName: "go-starter-site development CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+
+		// Only allow the CA to sign certificates for Hosts, so trusting it can't be abused to impersonate any other site.
+		PermittedDNSDomainsCritical: true,
+	}
+	for _, host := range Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			template.PermittedIPRanges = append(template.PermittedIPRanges, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			template.PermittedDNSDomains = append(template.PermittedDNSDomains, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writeKeyPair(files.CACert, files.CAKey, der, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// createCert generates a new localhost certificate signed by ca, and writes it to disk.
+func createCert(files Files, ca *x509.Certificate, caKey crypto.Signer) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"go-starter-site development certificate"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyPair(files.Cert, files.Key, der, key)
+}
+
+// serialNumber returns a random 128-bit serial number.
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// writeKeyPair writes a certificate and its private key to disk as PEM.
+// The key is only readable by the current user.
+func writeKeyPair(certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+// loadKeyPair reads a certificate and its private key from disk.
+func loadKeyPair(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		// Only report a missing file as such, so the caller knows it's safe to generate a new one.
+		for _, name := range []string{certFile, keyFile} {
+			if _, statErr := os.Stat(name); errors.Is(statErr, os.ErrNotExist) {
+				return nil, nil, statErr
+			}
+		}
+		return nil, nil, fmt.Errorf("devcert: loading %s: %w", certFile, err)
+	}
+
+	// Leaf is only nil if the x509keypairleaf GODEBUG setting is turned off.
+	if pair.Leaf == nil {
+		pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("devcert: unsupported private key in %s", keyFile)
+	}
+	return pair.Leaf, signer, nil
+}
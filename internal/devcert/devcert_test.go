@@ -0,0 +1,151 @@
+package devcert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestEnsure(t *testing.T) {
+	dir := t.TempDir()
+
+	files, generated, err := Ensure(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, generated, true)
+
+	// The localhost certificate should be trusted by anything that trusts the CA.
+	ca, _, err := loadKeyPair(files.CACert, files.CAKey)
+	assert.NilError(t, err)
+	cert, _, err := loadKeyPair(files.Cert, files.Key)
+	assert.NilError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range Hosts {
+		_, err = cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		assert.NilError(t, err)
+	}
+
+	// The private keys shouldn't be readable by anyone else.
+	info, err := os.Stat(files.CAKey)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+	// A second call should reuse everything.
+	before, err := os.ReadFile(files.Cert)
+	assert.NilError(t, err)
+
+	_, generated, err = Ensure(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, generated, false)
+
+	after, err := os.ReadFile(files.Cert)
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(before, after), true)
+}
+
+func TestEnsureRegeneratesCert(t *testing.T) {
+	dir := t.TempDir()
+	files, _, err := Ensure(dir)
+	assert.NilError(t, err)
+
+	caBefore, err := os.ReadFile(files.CACert)
+	assert.NilError(t, err)
+
+	// Removing the localhost certificate should only regenerate it, keeping the CA that's already trusted.
+	assert.NilError(t, os.Remove(files.Cert))
+	_, generated, err := Ensure(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, generated, true)
+
+	caAfter, err := os.ReadFile(files.CACert)
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(caBefore, caAfter), true)
+}
+
+func TestValid(t *testing.T) {
+	dir := t.TempDir()
+	files, _, err := Ensure(dir)
+	assert.NilError(t, err)
+
+	ca, _, err := loadKeyPair(files.CACert, files.CAKey)
+	assert.NilError(t, err)
+	cert, _, err := loadKeyPair(files.Cert, files.Key)
+	assert.NilError(t, err)
+
+	assert.Equal(t, valid(cert, ca), true)
+
+	// A certificate close to expiring should be replaced.
+	expiring := *cert
+	expiring.NotAfter = time.Now().Add(renewWithin - time.Hour)
+	assert.Equal(t, valid(&expiring, ca), false)
+
+	// A certificate from a different CA should be replaced.
+	otherFiles, _, err := Ensure(t.TempDir())
+	assert.NilError(t, err)
+	otherCA, _, err := loadKeyPair(otherFiles.CACert, otherFiles.CAKey)
+	assert.NilError(t, err)
+	assert.Equal(t, valid(cert, otherCA), false)
+}
+
+func TestCANameConstraints(t *testing.T) {
+	files, _, err := Ensure(t.TempDir())
+	assert.NilError(t, err)
+
+	ca, caKey, err := loadKeyPair(files.CACert, files.CAKey)
+	assert.NilError(t, err)
+	assert.Equal(t, constrained(ca), true)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	// Even if the CA's key is stolen, it can't be used to sign a certificate for another site.
+	tests := []struct {
+		name string
+		host string
+	}{
+		{name: "Domain", host: "example.com"},
+		{name: "Lookalike", host: "localhost.example.com"},
+		{name: "IPv4", host: "10.0.0.1"},
+		{name: "IPv6", host: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			assert.NilError(t, err)
+
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(1),
+				NotBefore:    time.Now().Add(-time.Hour),
+				NotAfter:     time.Now().Add(time.Hour),
+				ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}
+			if ip := net.ParseIP(tt.host); ip != nil {
+				template.IPAddresses = []net.IP{ip}
+			} else {
+				template.DNSNames = []string{tt.host}
+			}
+
+			der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+			assert.NilError(t, err)
+			cert, err := x509.ParseCertificate(der)
+			assert.NilError(t, err)
+
+			_, err = cert.Verify(x509.VerifyOptions{DNSName: tt.host, Roots: roots})
+			var invalid x509.CertificateInvalidError
+			assert.Equal(t, errors.As(err, &invalid), true)
+			assert.Equal(t, invalid.Reason, x509.CANotAuthorizedForThisName)
+		})
+	}
+}
@@ -6,54 +6,48 @@ import (
 	"time"
 )
 
-// GetBoolOrDefault will return the value of the environment variable name provided if it exists.
-// If the environment variable can't be found, or an error occurs, returns defaultV.
-func GetBoolOrDefault(name string, defaultV bool) bool {
-	v, ok := os.LookupEnv(name)
-	if !ok {
-		return defaultV
-	}
-	vAsBool, err := strconv.ParseBool(v)
-	if err != nil {
-		return defaultV
-	}
-	return vAsBool
+// GetBoolOrDefault will return the value of the environment variable name provided if it exists, or defaultV if it doesn't.
+// The value can also be read from a file pointed to by name_FILE, see Lookup().
+// If the value can't be looked up or parsed, defaultV is returned along with a *FieldError describing the problem.
+func GetBoolOrDefault(name string, defaultV bool) (bool, error) {
+	return get(name, defaultV, strconv.ParseBool)
 }
 
-// GetDurationOrDefault will return the value of the environment variable name provided if it exists.
-// If the environment variable can't be found, or an error occurs, returns defaultV.
-func GetDurationOrDefault(name string, defaultV time.Duration) time.Duration {
-	v, ok := os.LookupEnv(name)
-	if !ok {
-		return defaultV
-	}
-	vAsDuration, err := time.ParseDuration(v)
-	if err != nil {
-		return defaultV
-	}
-	return vAsDuration
+// GetDurationOrDefault will return the value of the environment variable name provided if it exists, or defaultV if it doesn't.
+// The value can also be read from a file pointed to by name_FILE, see Lookup().
+// If the value can't be looked up or parsed, defaultV is returned along with a *FieldError describing the problem.
+func GetDurationOrDefault(name string, defaultV time.Duration) (time.Duration, error) {
+	return get(name, defaultV, time.ParseDuration)
 }
 
-// GetIntOrDefault will return the value of the environment variable name provided if it exists.
-// If the environment variable can't be found, or an error occurs, returns defaultV.
-func GetIntOrDefault(name string, defaultV int) int {
-	v, ok := os.LookupEnv(name)
-	if !ok {
-		return defaultV
-	}
-	vAsInt, err := strconv.Atoi(v)
-	if err != nil {
-		return defaultV
-	}
-	return vAsInt
+// GetIntOrDefault will return the value of the environment variable name provided if it exists, or defaultV if it doesn't.
+// The value can also be read from a file pointed to by name_FILE, see Lookup().
+// If the value can't be looked up or parsed, defaultV is returned along with a *FieldError describing the problem.
+func GetIntOrDefault(name string, defaultV int) (int, error) {
+	return get(name, defaultV, strconv.Atoi)
 }
 
-// GetStringOrDefault will return the value of the environment variable name provided if it exists.
-// If the environment variable can't be found, returns defaultV.
-func GetStringOrDefault(name, defaultV string) string {
-	v, ok := os.LookupEnv(name)
+// GetStringOrDefault will return the value of the environment variable name provided if it exists, or defaultV if it doesn't.
+// The value can also be read from a file pointed to by name_FILE, see Lookup().
+// If the value can't be looked up, defaultV is returned along with a *FieldError describing the problem.
+func GetStringOrDefault(name, defaultV string) (string, error) {
+	return get(name, defaultV, func(v string) (string, error) { return v, nil })
+}
+
+// get looks up name and parses its value with parse, falling back to defaultV if it isn't set.
+func get[T any](name string, defaultV T, parse func(string) (T, error)) (T, error) {
+	v, ok, err := Lookup(name)
+	if err != nil {
+		return defaultV, &FieldError{Name: name, Err: err}
+	}
 	if !ok {
-		return defaultV
+		return defaultV, nil
+	}
+
+	parsed, err := parse(v)
+	if err != nil {
+		_, fromFile := os.LookupEnv(name + fileSuffix)
+		return defaultV, &FieldError{Name: name, Value: v, FromFile: fromFile, Err: err}
 	}
-	return v
+	return parsed, nil
 }
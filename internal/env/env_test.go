@@ -1,203 +1,160 @@
 package env
 
 import (
-	"os"
-	"strconv"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/rynhndrcksn/go-starter-site/internal/assert"
 )
 
+// checkFieldError checks err is a *FieldError for the "val" environment variable if wantErr is true, or nil otherwise.
+func checkFieldError(t *testing.T, err error, wantErr bool) {
+	t.Helper()
+
+	if !wantErr {
+		assert.NilError(t, err)
+		return
+	}
+
+	var fieldErr *FieldError
+	assert.Equal(t, errors.As(err, &fieldErr), true)
+	assert.Equal(t, fieldErr.Name, "val")
+}
+
 func TestGetBoolOrDefault(t *testing.T) {
 	tests := []struct {
-		name      string
-		valExists bool
-		want      bool
+		name    string
+		value   string
+		want    bool
+		wantErr bool
 	}{
 		{
-			name:      "gets the correct value from the environment",
-			valExists: true,
-			want:      true,
+			name:  "gets the correct value from the environment",
+			value: "true",
+			want:  true,
 		},
 		{
-			name:      "gets the default value if environment variable isn't set",
-			valExists: false,
-			want:      false,
+			name: "gets the default value if environment variable isn't set",
+			want: false,
 		},
 		{
-			name:      "gets the default value if environment variable isn't valid",
-			valExists: true,
-			want:      false,
+			name:    "returns the default value and an error if environment variable isn't valid",
+			value:   "not a bool",
+			want:    false,
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.valExists {
-				if tt.want == true {
-					err := os.Setenv("val", strconv.FormatBool(tt.want))
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				} else {
-					err := os.Setenv("val", "not a bool")
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				}
+			if tt.value != "" {
+				t.Setenv("val", tt.value)
 			}
 
-			got := GetBoolOrDefault("val", tt.want)
+			got, err := GetBoolOrDefault("val", false)
 			assert.Equal(t, got, tt.want)
-
-			if tt.valExists {
-				err := os.Unsetenv("val")
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			}
+			checkFieldError(t, err, tt.wantErr)
 		})
 	}
 }
 
 func TestGetDurationOrDefault(t *testing.T) {
 	tests := []struct {
-		name      string
-		valExists bool
-		want      time.Duration
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
 	}{
 		{
-			name:      "gets the correct value from the environment",
-			valExists: true,
-			want:      time.Second,
+			name:  "gets the correct value from the environment",
+			value: "1s",
+			want:  time.Second,
 		},
 		{
-			name:      "gets the default value if environment variable isn't set",
-			valExists: false,
-			want:      time.Millisecond,
+			name: "gets the default value if environment variable isn't set",
+			want: time.Millisecond,
 		},
 		{
-			name:      "gets the default value if environment variable isn't valid",
-			valExists: true,
-			want:      time.Millisecond,
+			name:    "returns the default value and an error if environment variable isn't valid",
+			value:   "not a time.Duration",
+			want:    time.Millisecond,
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.valExists {
-				if tt.want == time.Second {
-					err := os.Setenv("val", "1s")
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				} else {
-					err := os.Setenv("val", "not a time.Duration")
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				}
+			if tt.value != "" {
+				t.Setenv("val", tt.value)
 			}
 
-			got := GetDurationOrDefault("val", tt.want)
+			got, err := GetDurationOrDefault("val", time.Millisecond)
 			assert.Equal(t, got, tt.want)
-
-			if tt.valExists {
-				err := os.Unsetenv("val")
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			}
+			checkFieldError(t, err, tt.wantErr)
 		})
 	}
 }
 
 func TestGetIntOrDefault(t *testing.T) {
 	tests := []struct {
-		name      string
-		valExists bool
-		want      int
+		name    string
+		value   string
+		want    int
+		wantErr bool
 	}{
 		{
-			name:      "gets the correct value from the environment",
-			valExists: true,
-			want:      10,
+			name:  "gets the correct value from the environment",
+			value: "10",
+			want:  10,
 		},
 		{
-			name:      "gets the default value if environment variable isn't set",
-			valExists: false,
-			want:      1,
+			name: "gets the default value if environment variable isn't set",
+			want: 1,
 		},
 		{
-			name:      "gets the default value if environment variable isn't valid",
-			valExists: true,
-			want:      1,
+			name:    "returns the default value and an error if environment variable isn't valid",
+			value:   "not an int",
+			want:    1,
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.valExists {
-				if tt.want == 10 {
-					err := os.Setenv("val", strconv.FormatInt(int64(tt.want), 10))
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				} else {
-					err := os.Setenv("val", "not an int")
-					if err != nil {
-						t.Fatal(err.Error())
-					}
-				}
+			if tt.value != "" {
+				t.Setenv("val", tt.value)
 			}
 
-			got := GetIntOrDefault("val", tt.want)
+			got, err := GetIntOrDefault("val", 1)
 			assert.Equal(t, got, tt.want)
-
-			if tt.valExists {
-				err := os.Unsetenv("val")
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			}
+			checkFieldError(t, err, tt.wantErr)
 		})
 	}
 }
 
 func TestGetStringOrDefault(t *testing.T) {
 	tests := []struct {
-		name      string
-		valExists bool
-		want      string
+		name  string
+		value string
+		want  string
 	}{
 		{
-			name:      "gets the correct value from the environment",
-			valExists: true,
-			want:      "a string",
+			name:  "gets the correct value from the environment",
+			value: "a string",
+			want:  "a string",
 		},
 		{
-			name:      "gets the default value if environment variable isn't set",
-			valExists: false,
-			want:      "another string",
+			name: "gets the default value if environment variable isn't set",
+			want: "another string",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.valExists {
-				err := os.Setenv("val", tt.want)
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-
+			if tt.value != "" {
+				t.Setenv("val", tt.value)
 			}
 
-			got := GetStringOrDefault("val", tt.want)
+			got, err := GetStringOrDefault("val", "another string")
+			assert.NilError(t, err)
 			assert.Equal(t, got, tt.want)
-
-			if tt.valExists {
-				err := os.Unsetenv("val")
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			}
 		})
 	}
 }
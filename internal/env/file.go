@@ -0,0 +1,205 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var errUnsupportedFormat = errors.New("env: configuration file must be .json or .toml")
+
+// redacted replaces the value of fields tagged with secret:"true" in Dump().
+const redacted = "[redacted]"
+
+// LoadFile is like Load(), but values that aren't set in the environment are read from the JSON or TOML file at path,
+// before falling back to the defaults. This gives a precedence of default < file < environment.
+//
+// Each key in the file is the lowercase name of the environment variable, i.e. PORT is set by "port".
+// Tables (or objects in JSON) are joined to their keys with an underscore, so these are equivalent:
+//
+//	smtp_host = "localhost"
+//
+//	[smtp]
+//	host = "localhost"
+//
+// If path is empty, LoadFile behaves exactly like Load().
+func LoadFile(dst any, path string) error {
+	if path == "" {
+		return Load(dst)
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	return load(dst, values)
+}
+
+// readFile reads and flattens the configuration file at path, using its extension to pick the format.
+func readFile(path string) (map[string]any, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("env: %w", err)
+	}
+
+	var values map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(contents))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	case ".toml":
+		_, err = toml.Decode(string(contents), &values)
+	default:
+		return nil, errUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("env: parsing %s: %w", path, err)
+	}
+
+	flat := make(map[string]any)
+	flatten(flat, "", values)
+	return flat, nil
+}
+
+// flatten copies values into dst, joining the keys of nested tables to their parent's key with an underscore.
+func flatten(dst map[string]any, prefix string, values map[string]any) {
+	for key, value := range values {
+		key = fileKey(key)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		table, ok := value.(map[string]any)
+		if ok {
+			flatten(dst, key, table)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// fileKey returns the key used in configuration files for the environment variable name.
+func fileKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// fileString converts a value read from a configuration file into the same format as an environment variable,
+// so it can be parsed by setValue(). Arrays are joined using separator.
+func fileString(value any, separator string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if _, ok := item.([]any); ok {
+				return "", fmt.Errorf("item %d: nested arrays aren't supported", i)
+			}
+			s, err := fileString(item, separator)
+			if err != nil {
+				return "", fmt.Errorf("item %d: %w", i, err)
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, separator), nil
+	default:
+		return "", fmt.Errorf("%w %T", errUnsupportedType, value)
+	}
+}
+
+// Dump writes the struct src, which is usually something populated by Load(), to w in the same TOML format read by LoadFile().
+// Fields tagged with secret:"true" have their values redacted, so the output can be safely logged or shared.
+func Dump(w io.Writer, src any) error {
+	rv := reflect.ValueOf(src)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("env: Dump requires a struct")
+	}
+
+	var buf bytes.Buffer
+	dumpStruct(&buf, rv)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// dumpStruct writes a "key = value" line for every tagged field in the struct.
+func dumpStruct(buf *bytes.Buffer, rv reflect.Value) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if fv.Kind() == reflect.Struct && fv.Type() != urlType {
+				dumpStruct(buf, fv)
+			}
+			continue
+		}
+
+		value := formatValue(fv)
+		if field.Tag.Get("secret") == "true" && !fv.IsZero() {
+			value = strconv.Quote(redacted)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", fileKey(name), value)
+	}
+}
+
+// formatValue formats fv as a TOML value.
+func formatValue(fv reflect.Value) string {
+	switch fv.Type() {
+	case durationType:
+		return strconv.Quote(time.Duration(fv.Int()).String())
+	case urlType:
+		u := fv.Interface().(url.URL)
+		return strconv.Quote(u.String())
+	case reflect.PointerTo(urlType):
+		if fv.IsNil() {
+			return `""`
+		}
+		return strconv.Quote(fv.Interface().(*url.URL).String())
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return strconv.Quote(fv.String())
+	case reflect.Slice:
+		parts := make([]string, fv.Len())
+		for i := range fv.Len() {
+			parts[i] = formatValue(fv.Index(i))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprint(fv.Interface())
+	}
+}
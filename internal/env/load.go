@@ -0,0 +1,251 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLoadInvalidTarget = errors.New("env: Load requires a non-nil pointer to a struct")
+	ErrMissing           = errors.New("required but not set")
+	ErrUnknownKey        = errors.New("unknown configuration key")
+	errUnsupportedType   = errors.New("unsupported type")
+)
+
+// FieldError describes a single environment variable that couldn't be loaded.
+// FromFile is true if the value was read from a name_FILE file, in which case it's likely a secret, and isn't included in Error().
+type FieldError struct {
+	Name     string
+	Value    string
+	FromFile bool
+	Err      error
+}
+
+func (e *FieldError) Error() string {
+	switch {
+	case e.Value == "":
+		return fmt.Sprintf("%s: %s", e.Name, e.Err)
+	case e.FromFile:
+		return fmt.Sprintf("%s: invalid value in %s%s: %s", e.Name, e.Name, fileSuffix, e.Err)
+	default:
+		return fmt.Sprintf("%s: invalid value %q: %s", e.Name, e.Value, e.Err)
+	}
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	urlType      = reflect.TypeFor[url.URL]()
+)
+
+// Load populates the struct pointed to by dst from environment variables, using struct tags:
+//
+//	type config struct {
+//		Port    int           `env:"PORT" default:"4000"`
+//		DSN     string        `env:"DB_CONN" required:"true"`
+//		Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//		Hosts   []string      `env:"HOSTS" separator:";"`
+//	}
+//
+// Supported types are strings, bools, ints, uints, floats, time.Duration, url.URL (and *url.URL),
+// and slices of any of those, which are split on commas unless a separator tag is given.
+// Nested structs are loaded as well. Fields without an env tag are left alone.
+//
+// Like the Get*OrDefault functions, each value can also be read from a file pointed to by name_FILE, see Lookup().
+//
+// Every missing or malformed variable is reported, rather than stopping at the first one.
+// The returned error joins a *FieldError for each problem.
+func Load(dst any) error {
+	return load(dst, nil)
+}
+
+// load populates dst like Load() does, falling back to the values read from a configuration file before any defaults.
+// Any keys in the file that don't match a field are reported as well, as they're most likely typos.
+func load(dst any, file map[string]any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrLoadInvalidTarget
+	}
+
+	l := loader{file: file, used: make(map[string]bool)}
+	l.loadStruct(rv.Elem())
+
+	for _, key := range slices.Sorted(maps.Keys(file)) {
+		if !l.used[key] {
+			l.errs = append(l.errs, &FieldError{Name: key, Err: ErrUnknownKey})
+		}
+	}
+
+	return errors.Join(l.errs...)
+}
+
+// loader holds the state for a single call to load().
+type loader struct {
+	file map[string]any
+	used map[string]bool
+	errs []error
+}
+
+// loadStruct sets every tagged field in the struct, collecting any problems in l.errs.
+func (l *loader) loadStruct(rv reflect.Value) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+
+		// Unexported fields can't be set.
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			// Recurse into untagged nested structs, so related settings can be grouped together.
+			if fv.Kind() == reflect.Struct && fv.Type() != urlType {
+				l.loadStruct(fv)
+			}
+			continue
+		}
+
+		separator := field.Tag.Get("separator")
+		if separator == "" {
+			separator = ","
+		}
+
+		value, ok, err := Lookup(name)
+		if err != nil {
+			l.errs = append(l.errs, &FieldError{Name: name, Err: err})
+			continue
+		}
+		_, fromFile := os.LookupEnv(name + fileSuffix)
+
+		// Values from the configuration file are reported using their key, so they're easy to find in the file.
+		key := fileKey(name)
+		fileValue, inFile := l.file[key]
+		if inFile {
+			l.used[key] = true
+		}
+		if !ok && inFile {
+			name = key
+			value, err = fileString(fileValue, separator)
+			if err != nil {
+				l.errs = append(l.errs, &FieldError{Name: name, Err: err})
+				continue
+			}
+			ok = true
+		}
+
+		if !ok {
+			if field.Tag.Get("required") == "true" {
+				l.errs = append(l.errs, &FieldError{Name: name, Err: ErrMissing})
+				continue
+			}
+
+			value, ok = field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			fromFile = false
+		}
+
+		err = setValue(fv, value, separator)
+		if err != nil {
+			l.errs = append(l.errs, &FieldError{Name: name, Value: value, FromFile: fromFile, Err: err})
+		}
+	}
+}
+
+// setValue parses value into the type of fv and sets it.
+func setValue(fv reflect.Value, value, separator string) error {
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a valid duration")
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case urlType:
+		u, err := parseURL(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*u))
+		return nil
+	case reflect.PointerTo(urlType):
+		u, err := parseURL(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(u))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a valid bool")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("not a valid int")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("not a valid uint")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return errors.New("not a valid float")
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		// An empty value means an empty slice, rather than a slice containing one empty item.
+		var parts []string
+		if strings.TrimSpace(value) != "" {
+			parts = strings.Split(value, separator)
+		}
+
+		slice := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			err := setValue(slice.Index(i), strings.TrimSpace(part), separator)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("%w %s", errUnsupportedType, fv.Type())
+	}
+
+	return nil
+}
+
+// parseURL parses an absolute URL, such as "https://example.com".
+func parseURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("not a valid absolute URL")
+	}
+	return u, nil
+}
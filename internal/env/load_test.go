@@ -0,0 +1,130 @@
+package env
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+type testLoadConfig struct {
+	Port     int           `env:"TEST_PORT" default:"4000"`
+	Env      string        `env:"TEST_ENV" default:"development"`
+	DSN      string        `env:"TEST_DSN" required:"true"`
+	Debug    bool          `env:"TEST_DEBUG"`
+	Timeout  time.Duration `env:"TEST_TIMEOUT" default:"5s"`
+	Ratio    float64       `env:"TEST_RATIO" default:"0.5"`
+	Size     uint16        `env:"TEST_SIZE" default:"8"`
+	Hosts    []string      `env:"TEST_HOSTS"`
+	Ports    []int         `env:"TEST_PORTS" separator:";"`
+	BaseURL  url.URL       `env:"TEST_BASE_URL" default:"https://localhost:4000"`
+	Callback *url.URL      `env:"TEST_CALLBACK"`
+	SMTP     struct {
+		Host string `env:"TEST_SMTP_HOST" default:"localhost"`
+		Port int    `env:"TEST_SMTP_PORT" default:"1025"`
+	}
+	Untagged string
+	ignored  string
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_PORT", "8080")
+	t.Setenv("TEST_DSN", "postgres://localhost/db")
+	t.Setenv("TEST_DEBUG", "true")
+	t.Setenv("TEST_HOSTS", "a.example.com, b.example.com")
+	t.Setenv("TEST_PORTS", "80;443")
+	t.Setenv("TEST_CALLBACK", "https://example.com/callback")
+	t.Setenv("TEST_SMTP_HOST", "smtp.example.com")
+
+	cfg := testLoadConfig{Untagged: "unchanged"}
+	err := Load(&cfg)
+	assert.NilError(t, err)
+
+	// Values from the environment.
+	assert.Equal(t, cfg.Port, 8080)
+	assert.Equal(t, cfg.DSN, "postgres://localhost/db")
+	assert.Equal(t, cfg.Debug, true)
+	assert.Equal(t, slices.Equal(cfg.Hosts, []string{"a.example.com", "b.example.com"}), true)
+	assert.Equal(t, slices.Equal(cfg.Ports, []int{80, 443}), true)
+	assert.Equal(t, cfg.Callback.String(), "https://example.com/callback")
+	assert.Equal(t, cfg.SMTP.Host, "smtp.example.com")
+
+	// Values from the defaults.
+	assert.Equal(t, cfg.Env, "development")
+	assert.Equal(t, cfg.Timeout, 5*time.Second)
+	assert.Equal(t, cfg.Ratio, 0.5)
+	assert.Equal(t, cfg.Size, uint16(8))
+	assert.Equal(t, cfg.BaseURL.String(), "https://localhost:4000")
+	assert.Equal(t, cfg.SMTP.Port, 1025)
+
+	// Fields without tags shouldn't be touched.
+	assert.Equal(t, cfg.Untagged, "unchanged")
+	assert.Equal(t, cfg.ignored, "")
+}
+
+func TestLoadErrors(t *testing.T) {
+	// Every problem should be reported, not just the first one.
+	t.Setenv("TEST_PORT", "abc")
+	t.Setenv("TEST_TIMEOUT", "5 seconds")
+	t.Setenv("TEST_PORTS", "80;https")
+	t.Setenv("TEST_SIZE", "70000")
+	t.Setenv("TEST_BASE_URL", "localhost")
+
+	var cfg testLoadConfig
+	err := Load(&cfg)
+	if err == nil {
+		t.Fatal("got: nil; want: an error")
+	}
+
+	assert.Equal(t, errors.Is(err, ErrMissing), true)
+	for _, name := range []string{"TEST_PORT", "TEST_DSN", "TEST_TIMEOUT", "TEST_PORTS", "TEST_SIZE", "TEST_BASE_URL"} {
+		assert.StringContains(t, err.Error(), name+":")
+	}
+	assert.Equal(t, strings.Count(err.Error(), "\n"), 5)
+
+	var fieldErr *FieldError
+	assert.Equal(t, errors.As(err, &fieldErr), true)
+	assert.Equal(t, fieldErr.Name, "TEST_PORT")
+	assert.Equal(t, fieldErr.Value, "abc")
+}
+
+func TestLoadInvalidTarget(t *testing.T) {
+	var cfg testLoadConfig
+	tests := []struct {
+		name string
+		dst  any
+	}{
+		{
+			name: "Not a pointer",
+			dst:  cfg,
+		},
+		{
+			name: "Nil pointer",
+			dst:  (*testLoadConfig)(nil),
+		},
+		{
+			name: "Pointer to a non-struct",
+			dst:  new(int),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Load(tt.dst)
+			assert.Equal(t, errors.Is(err, ErrLoadInvalidTarget), true)
+		})
+	}
+}
+
+func TestLoadUnsupportedType(t *testing.T) {
+	t.Setenv("TEST_MAP", "a=b")
+
+	var cfg struct {
+		M map[string]string `env:"TEST_MAP"`
+	}
+	err := Load(&cfg)
+	assert.Equal(t, errors.Is(err, errUnsupportedType), true)
+}
@@ -0,0 +1,36 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fileSuffix is appended to a variable's name to read its value from a file instead, i.e. DB_CONN_FILE=/run/secrets/dsn.
+// This keeps secrets mounted by Docker or Kubernetes out of the process environment.
+const fileSuffix = "_FILE"
+
+// ErrSetTwice is returned by Lookup() when a variable is set both directly and with name_FILE.
+var ErrSetTwice = errors.New("set both directly and with " + fileSuffix + ", only one is allowed")
+
+// Lookup returns the value of the environment variable name, and whether it was set.
+// If name isn't set, but name_FILE is, the trimmed contents of the file it points to are returned instead.
+// An error is returned if both are set, or the file can't be read.
+func Lookup(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	path, fileOK := os.LookupEnv(name + fileSuffix)
+
+	switch {
+	case ok && fileOK:
+		return "", false, ErrSetTwice
+	case fileOK:
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("reading %s%s: %w", name, fileSuffix, err)
+		}
+		return strings.TrimSpace(string(contents)), true, nil
+	default:
+		return value, ok, nil
+	}
+}
@@ -0,0 +1,132 @@
+// Package handoff passes listening sockets from one process to the next, so the site can be restarted without refusing
+// any connections. Listeners can be inherited from a parent process started by Restart(), or from systemd socket activation.
+//
+// Both use the systemd convention: the sockets are passed as file descriptors starting at 3, with LISTEN_FDS set to how
+// many there are, and LISTEN_FDNAMES set to their colon separated names.
+//
+// Sockets can only be passed on Unix-like systems. Elsewhere, nothing is inherited and Restart() returns an error.
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// firstFD is the first file descriptor used for passed sockets, after stdin, stdout, and stderr.
+const firstFD = 3
+
+// Environment variables used to describe the passed sockets.
+// envReadyFD isn't part of the systemd convention, and is only set by Restart().
+const (
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+	envListenPID     = "LISTEN_PID"
+	envReadyFD       = "HANDOFF_READY_FD"
+)
+
+// DefaultReadyTimeout is how long Restart() waits for the new process to call Ready().
+const DefaultReadyTimeout = 30 * time.Second
+
+// Listeners keeps track of the listeners a process is using, so they can be passed on to a new process.
+type Listeners struct {
+	mu        sync.Mutex
+	inherited map[string]net.Listener
+	active    []namedListener
+	ready     *os.File
+
+	// These can be replaced in tests.
+	executable   string
+	args         []string
+	readyTimeout time.Duration
+}
+
+type namedListener struct {
+	name string
+	ln   net.Listener
+}
+
+// New returns the Listeners for this process, picking up any that were passed to it.
+// names are the names the process passes to Listen(). Passed sockets with any other name are named by their position
+// instead, using names in order. This covers sockets without a FileDescriptorName, which systemd names after the unit.
+// The environment variables describing the sockets are removed, so they aren't passed on to other processes.
+func New(names ...string) (*Listeners, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %w", err)
+	}
+
+	l := &Listeners{
+		inherited:    make(map[string]net.Listener),
+		executable:   executable,
+		args:         os.Args[1:],
+		readyTimeout: DefaultReadyTimeout,
+	}
+
+	err = l.inherit(os.Getenv, firstFD, names)
+	for _, name := range []string{envListenFDs, envListenFDNames, envListenPID, envReadyFD} {
+		_ = os.Unsetenv(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Listen returns the listener called name that was passed to this process, if there is one.
+// Otherwise, a new listener is opened on addr. Either way, it's passed on to the next process by Restart().
+func (l *Listeners) Listen(name, network, addr string) (net.Listener, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ln, ok := l.inherited[name]
+	if ok {
+		delete(l.inherited, name)
+	} else {
+		var err error
+		ln, err = net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l.active = append(l.active, namedListener{name: name, ln: ln})
+	return ln, nil
+}
+
+// Inherited reports whether any listeners were passed to this process.
+func (l *Listeners) Inherited() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.inherited) > 0 || l.ready != nil
+}
+
+// CloseUnused closes any passed listeners that haven't been claimed by Listen(), i.e. for a server that's been disabled.
+func (l *Listeners) CloseUnused() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for name, ln := range l.inherited {
+		errs = append(errs, ln.Close())
+		delete(l.inherited, name)
+	}
+	return errors.Join(errs...)
+}
+
+// Ready tells the parent process, if this process was started by Restart(), that it's ready to handle connections.
+func (l *Listeners) Ready() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.ready == nil {
+		return nil
+	}
+	_, err := l.ready.Write([]byte{1})
+	closeErr := l.ready.Close()
+	l.ready = nil
+	return errors.Join(err, closeErr)
+}
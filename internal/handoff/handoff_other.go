@@ -0,0 +1,20 @@
+//go:build !unix
+
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// inherit does nothing, as sockets can't be passed to a process as file descriptors on this platform.
+func (l *Listeners) inherit(getenv func(string) string, fd int, names []string) error {
+	return nil
+}
+
+// Restart isn't supported on this platform, so always returns an error wrapping errors.ErrUnsupported.
+func (l *Listeners) Restart() (*os.Process, error) {
+	return nil, fmt.Errorf("handoff: restarting on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
@@ -0,0 +1,159 @@
+//go:build unix
+
+package handoff
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inherit turns the sockets described by the environment, starting at the file descriptor fd, into listeners.
+func (l *Listeners) inherit(getenv func(string) string, fd int, names []string) error {
+	// systemd sets LISTEN_PID, so a process it starts can tell if the variables were meant for it, or a parent.
+	// Restart() can't know the new process ID in advance, so leaves it unset.
+	if pid := getenv(envListenPID); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+
+	if s := getenv(envListenFDs); s != "" {
+		count, err := strconv.Atoi(s)
+		if err != nil || count < 0 {
+			return fmt.Errorf("handoff: invalid %s %q", envListenFDs, s)
+		}
+
+		var fdNames []string
+		if s := getenv(envListenFDNames); s != "" {
+			fdNames = strings.Split(s, ":")
+		}
+
+		for i := range count {
+			name := fmt.Sprintf("fd%d", fd+i)
+			switch {
+			case i < len(fdNames) && slices.Contains(names, fdNames[i]):
+				name = fdNames[i]
+			case i < len(names):
+				name = names[i]
+			}
+
+			// FileListener duplicates the file descriptor, so the original is closed either way.
+			f := os.NewFile(uintptr(fd+i), name)
+			ln, err := net.FileListener(f)
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("handoff: listener %q: %w", name, err)
+			}
+			l.inherited[name] = ln
+		}
+	}
+
+	if s := getenv(envReadyFD); s != "" {
+		readyFD, err := strconv.Atoi(s)
+		if err != nil || readyFD < 0 {
+			return fmt.Errorf("handoff: invalid %s %q", envReadyFD, s)
+		}
+		l.ready = os.NewFile(uintptr(readyFD), "ready")
+	}
+	return nil
+}
+
+// Restart starts a new copy of this process, with the same arguments, passing it the listeners opened by Listen().
+// It returns once the new process has called Ready(), at which point it's safe for this process to shut down.
+// If the new process exits or doesn't become ready in time, an error is returned and this process carries on as normal.
+func (l *Listeners) Restart() (*os.Process, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		files []*os.File
+		names []string
+	)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, active := range l.active {
+		filer, ok := active.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("handoff: listener %q can't be passed to another process", active.name)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return nil, fmt.Errorf("handoff: listener %q: %w", active.name, err)
+		}
+		files = append(files, f)
+		names = append(names, active.name)
+	}
+
+	// The new process closes its end of the pipe once it's ready, after writing a byte to it.
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %w", err)
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(l.executable, l.args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(environ(),
+		fmt.Sprintf("%s=%d", envListenFDs, len(files)),
+		fmt.Sprintf("%s=%s", envListenFDNames, strings.Join(names, ":")),
+		fmt.Sprintf("%s=%d", envReadyFD, firstFD+len(files)),
+	)
+
+	err = cmd.Start()
+	_ = readyW.Close()
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %w", err)
+	}
+
+	// Wait() reaps the new process if it exits early. Once this process exits, it's adopted by init instead.
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := readyR.Read(b)
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+		if err == nil {
+			return cmd.Process, nil
+		}
+		// The pipe was closed without a byte being written, so the process has exited (or closed it by mistake).
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("handoff: new process wasn't ready: %w", err)
+	case err = <-exited:
+		return nil, fmt.Errorf("handoff: new process exited: %v", err)
+	case <-time.After(l.readyTimeout):
+		_ = cmd.Process.Kill()
+		return nil, fmt.Errorf("handoff: new process wasn't ready within %s", l.readyTimeout)
+	}
+}
+
+// environ returns the environment without any variables describing passed sockets, which Restart() replaces.
+func environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case envListenFDs, envListenFDNames, envListenPID, envReadyFD:
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
@@ -0,0 +1,155 @@
+//go:build unix
+
+package handoff
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+// TestHelperProcess isn't a real test. It's run as the new process by the Restart() tests, depending on HANDOFF_TEST_HELPER.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv("HANDOFF_TEST_HELPER") {
+	case "serve":
+		l, err := New("main")
+		if err != nil {
+			os.Exit(2)
+		}
+		ln, err := l.Listen("main", "tcp", "127.0.0.1:0")
+		if err != nil || l.Ready() != nil {
+			os.Exit(2)
+		}
+
+		// Answer a single connection, so the test can tell which process accepted it.
+		conn, err := ln.Accept()
+		if err != nil {
+			os.Exit(2)
+		}
+		_, _ = conn.Write([]byte("new process\n"))
+		_ = conn.Close()
+		os.Exit(0)
+	case "fail":
+		os.Exit(1)
+	}
+}
+
+// newTestListeners returns Listeners that restart by running TestHelperProcess.
+func newTestListeners(t *testing.T, helper string) *Listeners {
+	t.Setenv("HANDOFF_TEST_HELPER", helper)
+	return &Listeners{
+		inherited:    make(map[string]net.Listener),
+		executable:   os.Args[0],
+		args:         []string{"-test.run=^TestHelperProcess$"},
+		readyTimeout: 10 * time.Second,
+	}
+}
+
+func TestRestart(t *testing.T) {
+	l := newTestListeners(t, "serve")
+	ln, err := l.Listen("main", "tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	_, err = l.Restart()
+	assert.NilError(t, err)
+
+	// Once this process stops listening, connections go to the new one, which has the same socket.
+	addr := ln.Addr().String()
+	assert.NilError(t, ln.Close())
+
+	conn, err := net.Dial("tcp", addr)
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, "new process\n")
+}
+
+func TestRestartFailed(t *testing.T) {
+	l := newTestListeners(t, "fail")
+	ln, err := l.Listen("main", "tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer ln.Close()
+
+	_, err = l.Restart()
+	assert.Equal(t, err != nil, true)
+}
+
+func TestInherit(t *testing.T) {
+	// Pass a duplicate of a real listener's file descriptor, as if it had been inherited.
+	original, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer original.Close()
+
+	f, err := original.(*net.TCPListener).File()
+	assert.NilError(t, err)
+	defer f.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantName string
+	}{
+		{
+			name:     "Named by LISTEN_FDNAMES",
+			env:      map[string]string{envListenFDs: "1", envListenFDNames: "admin"},
+			wantName: "admin",
+		},
+		{
+			name:     "Unknown name",
+			env:      map[string]string{envListenFDs: "1", envListenFDNames: "site.socket"},
+			wantName: "main",
+		},
+		{
+			name:     "Named by default",
+			env:      map[string]string{envListenFDs: "1", envListenPID: strconv.Itoa(os.Getpid())},
+			wantName: "main",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each test case needs its own file descriptor, as inherit() closes it.
+			if i > 0 {
+				fd, err = syscall.Dup(int(f.Fd()))
+				assert.NilError(t, err)
+			}
+
+			l := &Listeners{inherited: make(map[string]net.Listener)}
+			err := l.inherit(func(name string) string { return tt.env[name] }, fd, []string{"main", "admin"})
+			assert.NilError(t, err)
+			assert.Equal(t, l.Inherited(), true)
+
+			ln, err := l.Listen(tt.wantName, "tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+			defer ln.Close()
+			assert.Equal(t, ln.Addr().String(), original.Addr().String())
+			assert.Equal(t, l.Inherited(), false)
+		})
+	}
+}
+
+func TestInheritOtherProcess(t *testing.T) {
+	// Variables meant for a different process (i.e. a parent started by systemd) are ignored.
+	env := map[string]string{envListenFDs: "1", envListenPID: "1"}
+	l := &Listeners{inherited: make(map[string]net.Listener)}
+	err := l.inherit(func(name string) string { return env[name] }, 1000, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, l.Inherited(), false)
+}
+
+func TestInheritInvalid(t *testing.T) {
+	env := map[string]string{envListenFDs: "lots"}
+	l := &Listeners{inherited: make(map[string]net.Listener)}
+	err := l.inherit(func(name string) string { return env[name] }, firstFD, nil)
+	assert.Equal(t, err != nil, true)
+}
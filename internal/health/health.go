@@ -0,0 +1,204 @@
+// Package health implements liveness and readiness checks, for load balancers and container orchestrators to poll.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is how long a check gets to finish if it was added with a timeout of zero.
+const DefaultTimeout = 2 * time.Second
+
+// Statuses reported for the site as a whole, and for each check.
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// CheckFunc checks whether a dependency (such as the database) is working, returning an error if it isn't.
+// It should give up once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Checker runs the registered checks to decide whether the site is ready to handle requests.
+// The zero value has no checks, and is ready until Shutdown() is called.
+type Checker struct {
+	mu     sync.Mutex
+	checks []check
+
+	// cacheMu is held while the checks run for ReadyHandler(), so concurrent requests share a single run.
+	cacheMu  sync.Mutex
+	cached   Report
+	cachedAt time.Time
+
+	shuttingDown atomic.Bool
+}
+
+// Report is the outcome of running the checks, which is served as JSON.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// New returns a Checker without any checks.
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add registers a check, which fails if fn doesn't return within timeout.
+func (c *Checker) Add(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn})
+}
+
+// Shutdown marks the site as shutting down, so it stops being ready and load balancers stop sending it new requests.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether Shutdown() has been called.
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Check runs every check at the same time, and reports whether they all passed.
+// The checks are skipped once shutdown has begun, as the answer won't change.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = chk.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, chk := range checks {
+		report.Checks[chk.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// cachedCheck returns the report from the last call if it's younger than maxAge, otherwise it runs the checks again.
+// Shutting down is always reported straight away.
+func (c *Checker) cachedCheck(ctx context.Context, maxAge time.Duration) Report {
+	if c.ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	if !c.cachedAt.IsZero() && time.Since(c.cachedAt) < maxAge {
+		return c.cached
+	}
+
+	// The report is shared with other requests, so it mustn't fail because this one was cancelled.
+	c.cached = c.Check(context.WithoutCancel(ctx))
+	c.cachedAt = time.Now()
+	return c.cached
+}
+
+// run runs the check with its timeout. The check's result is ignored if it doesn't respect ctx and finishes late.
+func (chk check) run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, chk.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- chk.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// LiveHandler reports that the process is alive and serving requests. It doesn't run any checks, so a broken dependency
+// doesn't get the process restarted, and it keeps passing during shutdown, so the process isn't killed while draining.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// ReadyHandler responds with a 200 if the checks all passed, or a 503 if any failed or shutdown has begun.
+// It's meant to be public, so only the overall status is served, and the checks' errors aren't exposed.
+// The checks are run at most once every maxAge, so polling it often can't be used to overload the site's dependencies.
+func (c *Checker) ReadyHandler(maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.cachedCheck(r.Context(), maxAge)
+		writeReport(w, Report{Status: report.Status})
+	})
+}
+
+// ReportHandler runs the checks every time, and serves the full report, including each check's error and duration.
+// It uses the same status codes as ReadyHandler(), but should only be served to operators, i.e. on an admin listener.
+func (c *Checker) ReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Check(r.Context()))
+	})
+}
+
+// writeReport serves report, with a 503 status code unless it's OK.
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
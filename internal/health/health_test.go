@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestCheck(t *testing.T) {
+	c := New()
+	c.Add("database", 0, func(ctx context.Context) error { return nil })
+	c.Add("cache", 0, func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report := c.Check(context.Background())
+	assert.Equal(t, report.Status, StatusFail)
+	assert.Equal(t, report.Checks["database"].Status, StatusOK)
+	assert.Equal(t, report.Checks["cache"].Status, StatusFail)
+	assert.Equal(t, report.Checks["cache"].Error, "connection refused")
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := New()
+
+	// This check ignores its context, so it has to be abandoned once the timeout is hit.
+	release := make(chan struct{})
+	defer close(release)
+	c.Add("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	assert.Equal(t, time.Since(start) < time.Second, true)
+	assert.Equal(t, report.Status, StatusFail)
+	assert.Equal(t, report.Checks["stuck"].Error, context.DeadlineExceeded.Error())
+}
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkErr   error
+		shutdown   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "Ready",
+			wantCode:   http.StatusOK,
+			wantStatus: StatusOK,
+		},
+		{
+			name:       "Failing check",
+			checkErr:   errors.New("down"),
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusFail,
+		},
+		{
+			name:       "Shutting down",
+			shutdown:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusShuttingDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.Add("database", 0, func(ctx context.Context) error { return tt.checkErr })
+			if tt.shutdown {
+				c.Shutdown()
+			}
+
+			rr := httptest.NewRecorder()
+			c.ReadyHandler(0).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var report Report
+			err := json.NewDecoder(rr.Body).Decode(&report)
+			assert.NilError(t, err)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+			assert.Equal(t, report.Status, tt.wantStatus)
+
+			// The public endpoint doesn't say which check failed, or why.
+			assert.Equal(t, len(report.Checks), 0)
+		})
+	}
+}
+
+func TestReadyHandlerCache(t *testing.T) {
+	c := New()
+	runs := 0
+	var checkErr error
+	c.Add("database", 0, func(ctx context.Context) error {
+		runs++
+		return checkErr
+	})
+	handler := c.ReadyHandler(time.Hour)
+
+	get := func() int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rr.Code
+	}
+
+	// The result is reused until it's older than maxAge.
+	assert.Equal(t, get(), http.StatusOK)
+	checkErr = errors.New("down")
+	assert.Equal(t, get(), http.StatusOK)
+	assert.Equal(t, runs, 1)
+
+	// Except for shutting down, which is reported straight away.
+	c.Shutdown()
+	assert.Equal(t, get(), http.StatusServiceUnavailable)
+	assert.Equal(t, runs, 1)
+}
+
+func TestReportHandler(t *testing.T) {
+	c := New()
+	c.Add("database", 0, func(ctx context.Context) error { return errors.New("connection refused") })
+
+	rr := httptest.NewRecorder()
+	c.ReportHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	err := json.NewDecoder(rr.Body).Decode(&report)
+	assert.NilError(t, err)
+
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, report.Status, StatusFail)
+	assert.Equal(t, report.Checks["database"].Error, "connection refused")
+}
+
+func TestLiveHandler(t *testing.T) {
+	c := New()
+	c.Add("database", 0, func(ctx context.Context) error { return errors.New("down") })
+	c.Shutdown()
+
+	// Liveness doesn't depend on the checks, or on shutting down.
+	rr := httptest.NewRecorder()
+	c.LiveHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.StringContains(t, rr.Body.String(), `"status":"ok"`)
+}
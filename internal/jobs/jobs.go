@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+)
+
+var (
+	errJobsAlreadyStarted = errors.New("job queue has already been started")
+	errJobsNoHandler      = errors.New("no handler registered for job")
+)
+
+// Store is the storage the Queue needs for its jobs. data.JobModel implements it using Postgres.
+type Store interface {
+	Insert(name string, payload []byte, maxAttempts int, runAt time.Time) (int, error)
+	Claim(lockTimeout time.Duration) (data.Job, error)
+	Complete(id int) error
+	Retry(id int, runAt time.Time, lastError string) error
+	Kill(id int, lastError string) error
+	DeleteFinished(before time.Time) (int64, error)
+}
+
+// HandlerFunc runs a single job, using the JSON payload it was enqueued with.
+// Returning an error causes the job to be retried with exponential backoff, until it runs out of attempts.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// Config holds the settings for a Queue.
+type Config struct {
+	// Workers is how many jobs can run at the same time.
+	Workers int
+	// PollInterval is how long an idle worker waits before checking for new jobs.
+	PollInterval time.Duration
+	// MaxAttempts is how many times a job is tried before it's moved to the dead state.
+	MaxAttempts int
+	// LockTimeout is how long a job can be running before it's assumed its worker died, and it's run again.
+	LockTimeout time.Duration
+	// Retention is how long completed and dead jobs are kept before they're deleted. Zero keeps them forever.
+	Retention time.Duration
+	// SweepInterval is how often completed and dead jobs older than Retention are deleted.
+	SweepInterval time.Duration
+}
+
+// Queue runs jobs from a Store using a pool of workers.
+type Queue struct {
+	store    Store
+	logger   *slog.Logger
+	config   Config
+	handlers map[string]HandlerFunc
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New returns a new Queue. Handlers need to be registered before calling Start().
+func New(store Store, logger *slog.Logger, config Config) *Queue {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = 15 * time.Minute
+	}
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = time.Hour
+	}
+
+	return &Queue{
+		store:    store,
+		logger:   logger,
+		config:   config,
+		handlers: make(map[string]HandlerFunc),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for jobs with the given name.
+func (q *Queue) Handle(name string, fn HandlerFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.handlers[name] = fn
+}
+
+// Register registers a handler for jobs with the given name, which receives the job's payload decoded into a T.
+// Payloads that can't be decoded are never going to succeed, so the job is killed straight away instead of retried.
+func Register[T any](q *Queue, name string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(name, func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		err := json.Unmarshal(raw, &payload)
+		if err != nil {
+			return Permanent(fmt.Errorf("decoding payload: %w", err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Enqueue JSON encodes the payload and adds a new job with the given name to the queue.
+func (q *Queue) Enqueue(name string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.store.Insert(name, b, q.config.MaxAttempts, time.Now())
+	return err
+}
+
+// Start launches the workers in the background.
+func (q *Queue) Start() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return errJobsAlreadyStarted
+	}
+	q.started = true
+
+	// Jobs get a context that's only cancelled if Shutdown() runs out of time waiting for them.
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	for range q.config.Workers {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+
+	if q.config.Retention > 0 {
+		q.wg.Add(1)
+		go q.sweep()
+	}
+
+	q.logger.Info("job queue started", slog.Int("workers", q.config.Workers))
+	return nil
+}
+
+// Shutdown stops the workers from claiming new jobs, and waits for any running jobs to finish.
+// If the context expires first, the running jobs' contexts are cancelled and the context's error is returned.
+// Jobs that don't stop in time are picked up again by a worker once their lock times out.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.started {
+		q.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// work claims and runs jobs until the queue is stopped.
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		// Check if the queue has been stopped before claiming another job.
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.store.Claim(q.config.LockTimeout)
+		if err != nil {
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				q.logger.Error("claiming job", slog.String("error", err.Error()))
+			}
+
+			// There's nothing to do right now (or the database is having a problem), so wait before trying again.
+			select {
+			case <-q.stop:
+				return
+			case <-time.After(q.config.PollInterval):
+			}
+			continue
+		}
+
+		q.run(ctx, job)
+	}
+}
+
+// sweep deletes completed and dead jobs once they're older than the retention period, until the queue is stopped.
+// It runs once straight away, then every SweepInterval.
+func (q *Queue) sweep() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.config.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := q.store.DeleteFinished(time.Now().Add(-q.config.Retention))
+		if err != nil {
+			q.logger.Error("deleting finished jobs", slog.String("error", err.Error()))
+		} else if deleted > 0 {
+			q.logger.Info("deleted finished jobs", slog.Int64("count", deleted))
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a claimed job and records the outcome in the store.
+func (q *Queue) run(ctx context.Context, job data.Job) {
+	logger := q.logger.With(slog.Int("job_id", job.ID), slog.String("job", job.Name), slog.Int("attempt", job.Attempts))
+
+	q.mu.Lock()
+	fn, ok := q.handlers[job.Name]
+	q.mu.Unlock()
+
+	var err error
+	switch {
+	case !ok:
+		err = Permanent(fmt.Errorf("%w %q", errJobsNoHandler, job.Name))
+	case job.Attempts > job.MaxAttempts:
+		// This happens when a job's worker died during its last attempt.
+		err = Permanent(errors.New("job exceeded its max attempts"))
+	default:
+		start := time.Now()
+		err = safeCall(ctx, fn, job.Payload)
+		logger = logger.With(slog.Duration("duration", time.Since(start)))
+	}
+
+	if err == nil {
+		err = q.store.Complete(job.ID)
+		if err != nil {
+			logger.Error("completing job", slog.String("error", err.Error()))
+			return
+		}
+		logger.Info("job completed")
+		return
+	}
+
+	var perm *permanentError
+	if errors.As(err, &perm) || job.Attempts >= job.MaxAttempts {
+		logger.Error("job failed permanently", slog.String("error", err.Error()))
+		err = q.store.Kill(job.ID, err.Error())
+		if err != nil {
+			logger.Error("killing job", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	delay := backoff(job.Attempts)
+	logger.Warn("job failed, retrying", slog.String("error", err.Error()), slog.Duration("retry_in", delay))
+	err = q.store.Retry(job.ID, time.Now().Add(delay), err.Error())
+	if err != nil {
+		logger.Error("retrying job", slog.String("error", err.Error()))
+	}
+}
+
+// safeCall runs the handler, turning any panic into an error so it doesn't take down the worker.
+func safeCall(ctx context.Context, fn HandlerFunc, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return fn(ctx, payload)
+}
+
+// backoff returns how long to wait before retrying a job that has failed the given number of attempts.
+// The delay doubles with each attempt, starting at 10 seconds and capped at 1 hour, plus up to 10% random jitter
+// so failed jobs don't all retry at the same moment.
+func backoff(attempts int) time.Duration {
+	const (
+		base     = 10 * time.Second
+		maxDelay = time.Hour
+	)
+
+	delay := maxDelay
+	if attempts < 20 {
+		delay = min(base<<max(attempts-1, 0), maxDelay)
+	}
+
+	return delay + rand.N(delay/10+1)
+}
+
+// permanentError wraps errors that mean a job should never be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as one that retrying won't fix.
+// Handlers can return it to move the job to the dead state straight away, instead of retrying it.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
@@ -0,0 +1,337 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/internal/data"
+)
+
+// memoryStore is an in-memory Store used to test the Queue without a database.
+// Deleted jobs are replaced with an empty job, so the rest keep their IDs.
+type memoryStore struct {
+	mu        sync.Mutex
+	jobs      []data.Job
+	updatedAt map[int]time.Time
+}
+
+func (s *memoryStore) Insert(name string, payload []byte, maxAttempts int, runAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := len(s.jobs) + 1
+	s.jobs = append(s.jobs, data.Job{ID: id, Name: name, Payload: payload, State: data.JobStatePending, MaxAttempts: maxAttempts, RunAt: runAt})
+	return id, nil
+}
+
+func (s *memoryStore) Claim(lockTimeout time.Duration) (data.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.jobs {
+		if s.jobs[i].State == data.JobStatePending && !s.jobs[i].RunAt.After(time.Now()) {
+			s.jobs[i].State = data.JobStateRunning
+			s.jobs[i].Attempts++
+			return s.jobs[i], nil
+		}
+	}
+	return data.Job{}, data.ErrRecordNotFound
+}
+
+func (s *memoryStore) Complete(id int) error {
+	return s.update(id, func(j *data.Job) { j.State = data.JobStateCompleted })
+}
+
+func (s *memoryStore) Retry(id int, runAt time.Time, lastError string) error {
+	return s.update(id, func(j *data.Job) {
+		j.State = data.JobStatePending
+		j.RunAt = runAt
+		j.LastError = lastError
+	})
+}
+
+func (s *memoryStore) Kill(id int, lastError string) error {
+	return s.update(id, func(j *data.Job) {
+		j.State = data.JobStateDead
+		j.LastError = lastError
+	})
+}
+
+func (s *memoryStore) DeleteFinished(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for i, job := range s.jobs {
+		finished := job.State == data.JobStateCompleted || job.State == data.JobStateDead
+		if finished && s.updatedAt[job.ID].Before(before) {
+			s.jobs[i] = data.Job{ID: job.ID}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryStore) update(id int, fn func(j *data.Job)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.jobs[id-1])
+	if s.updatedAt == nil {
+		s.updatedAt = make(map[int]time.Time)
+	}
+	s.updatedAt[id] = time.Now()
+	return nil
+}
+
+func (s *memoryStore) get(id int) data.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.jobs[id-1]
+}
+
+// waitForState polls the store until the job reaches the state, failing the test if it takes too long.
+func waitForState(t *testing.T, store *memoryStore, id int, state string) data.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job := store.get(id)
+		if job.State == state {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job %d never reached state %q, got: %q", id, state, store.get(id).State)
+	return data.Job{}
+}
+
+func newTestQueue(t *testing.T, store *memoryStore, maxAttempts int) *Queue {
+	q := New(store, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		Workers:      2,
+		PollInterval: 5 * time.Millisecond,
+		MaxAttempts:  maxAttempts,
+	})
+	t.Cleanup(func() {
+		_ = q.Shutdown(context.Background())
+	})
+	return q
+}
+
+func TestQueue(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		jobName     string
+		handler     func(ctx context.Context, p payload) error
+		wantState   string
+		wantError   string
+	}{
+		{
+			name:        "Successful job",
+			maxAttempts: 3,
+			jobName:     "greet",
+			handler: func(ctx context.Context, p payload) error {
+				if p.Name != "Bob" {
+					return errors.New("wrong payload")
+				}
+				return nil
+			},
+			wantState: data.JobStateCompleted,
+		},
+		{
+			name:        "Failing job is retried",
+			maxAttempts: 3,
+			jobName:     "greet",
+			handler: func(ctx context.Context, p payload) error {
+				return errors.New("try again")
+			},
+			wantState: data.JobStatePending,
+			wantError: "try again",
+		},
+		{
+			name:        "Failing job on its last attempt is dead",
+			maxAttempts: 1,
+			jobName:     "greet",
+			handler: func(ctx context.Context, p payload) error {
+				return errors.New("out of attempts")
+			},
+			wantState: data.JobStateDead,
+			wantError: "out of attempts",
+		},
+		{
+			name:        "Permanent errors aren't retried",
+			maxAttempts: 3,
+			jobName:     "greet",
+			handler: func(ctx context.Context, p payload) error {
+				return Permanent(errors.New("never going to work"))
+			},
+			wantState: data.JobStateDead,
+			wantError: "never going to work",
+		},
+		{
+			name:        "Panicking job is retried",
+			maxAttempts: 3,
+			jobName:     "greet",
+			handler: func(ctx context.Context, p payload) error {
+				panic("oh no")
+			},
+			wantState: data.JobStatePending,
+			wantError: "panic: oh no",
+		},
+		{
+			name:        "Job without a handler is dead",
+			maxAttempts: 3,
+			jobName:     "unknown",
+			handler: func(ctx context.Context, p payload) error {
+				return nil
+			},
+			wantState: data.JobStateDead,
+			wantError: "no handler registered for job",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{}
+			q := newTestQueue(t, store, tt.maxAttempts)
+			Register(q, "greet", tt.handler)
+
+			err := q.Enqueue(tt.jobName, payload{Name: "Bob"})
+			assert.NilError(t, err)
+			assert.NilError(t, q.Start())
+
+			var job data.Job
+			if tt.wantState == data.JobStatePending {
+				// Retried jobs go back to pending with a run_at in the future, so wait for the error to be recorded.
+				deadline := time.Now().Add(2 * time.Second)
+				for time.Now().Before(deadline) {
+					job = store.get(1)
+					if job.LastError != "" {
+						break
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+				assert.Equal(t, job.RunAt.After(time.Now()), true)
+			} else {
+				job = waitForState(t, store, 1, tt.wantState)
+			}
+
+			assert.Equal(t, job.State, tt.wantState)
+			assert.StringContains(t, job.LastError, tt.wantError)
+		})
+	}
+}
+
+func TestQueueShutdown(t *testing.T) {
+	store := &memoryStore{}
+	q := newTestQueue(t, store, 1)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.Handle("slow", func(ctx context.Context, _ json.RawMessage) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	assert.NilError(t, q.Enqueue("slow", nil))
+	assert.NilError(t, q.Start())
+	<-started
+
+	// Shutdown should time out while the job is still running.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := q.Shutdown(ctx)
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+
+	// Once the job finishes, Shutdown should return straight away, and no new jobs should be claimed.
+	close(release)
+	waitForState(t, store, 1, data.JobStateCompleted)
+	assert.NilError(t, q.Shutdown(context.Background()))
+
+	assert.NilError(t, q.Enqueue("slow", nil))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, store.get(2).State, data.JobStatePending)
+}
+
+func TestQueueSweep(t *testing.T) {
+	store := &memoryStore{}
+	q := New(store, slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		PollInterval:  5 * time.Millisecond,
+		Retention:     time.Hour,
+		SweepInterval: 5 * time.Millisecond,
+	})
+	t.Cleanup(func() {
+		_ = q.Shutdown(context.Background())
+	})
+
+	// Two jobs finished a while ago, and one has only just finished.
+	for range 3 {
+		assert.NilError(t, q.Enqueue("greet", nil))
+	}
+	assert.NilError(t, store.Complete(1))
+	assert.NilError(t, store.Kill(2, "out of attempts"))
+	assert.NilError(t, store.Complete(3))
+	store.mu.Lock()
+	store.updatedAt[1] = time.Now().Add(-2 * time.Hour)
+	store.updatedAt[2] = time.Now().Add(-2 * time.Hour)
+	store.mu.Unlock()
+
+	assert.NilError(t, q.Start())
+
+	// Only the jobs that finished before the retention period are deleted.
+	waitForState(t, store, 1, "")
+	waitForState(t, store, 2, "")
+	assert.Equal(t, store.get(3).State, data.JobStateCompleted)
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "First attempt",
+			attempts: 1,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "Third attempt",
+			attempts: 3,
+			want:     40 * time.Second,
+		},
+		{
+			name:     "Capped at an hour",
+			attempts: 12,
+			want:     time.Hour,
+		},
+		{
+			name:     "Very large attempts don't overflow",
+			attempts: 100,
+			want:     time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := backoff(tt.attempts)
+
+			// Allow for up to 10% jitter.
+			assert.Equal(t, got >= tt.want, true)
+			assert.Equal(t, got <= tt.want+tt.want/10, true)
+		})
+	}
+}
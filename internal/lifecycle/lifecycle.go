@@ -0,0 +1,158 @@
+// Package lifecycle coordinates shutting the site down gracefully once it receives a signal.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// Hook releases a resource during shutdown, such as stopping a server or closing a connection pool.
+// It should give up once ctx is done.
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// Manager runs shutdown in two phases:
+//
+//  1. Draining: the drain hooks are run (i.e. to start failing readiness checks), then the manager waits for the drain delay.
+//     This gives load balancers time to notice, and stop sending new requests, before anything is stopped.
+//  2. Stopping: the shutdown hooks are run in the reverse order they were added, like deferred function calls,
+//     so things are stopped before whatever they depend on. They share the timeout as a deadline.
+//
+// When shutdown is started by a signal, a second signal exits immediately.
+// Shutdown can also be started by Handover(), when another process has taken over the site's listeners.
+type Manager struct {
+	drainDelay time.Duration
+	timeout    time.Duration
+	logger     *slog.Logger
+
+	mu         sync.Mutex
+	drainHooks []func()
+	hooks      []hook
+	handover   chan string
+
+	// exit can be replaced in tests.
+	exit func(code int)
+}
+
+// New returns a Manager that waits for drainDelay before stopping anything, and then allows timeout for the shutdown hooks.
+func New(logger *slog.Logger, drainDelay, timeout time.Duration) *Manager {
+	return &Manager{
+		drainDelay: drainDelay,
+		timeout:    timeout,
+		logger:     logger,
+		handover:   make(chan string, 1),
+		exit:       os.Exit,
+	}
+}
+
+// OnDrain adds fn to be run as soon as shutdown begins.
+func (m *Manager) OnDrain(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drainHooks = append(m.drainHooks, fn)
+}
+
+// OnShutdown adds a hook to be run once draining is done. Hooks are run in the reverse order they were added.
+func (m *Manager) OnShutdown(name string, fn Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Handover starts shutting down without draining, because another process has taken over the listeners and is already
+// handling new requests. It makes Wait() return, as if a signal had been received. reason is logged.
+func (m *Manager) Handover(reason string) {
+	select {
+	case m.handover <- reason:
+	default:
+		// Shutdown has already been started.
+	}
+}
+
+// Wait blocks until one of signals is received (or Handover() is called), then shuts down, returning any errors
+// from the shutdown hooks. If another signal is received before shutdown finishes, the process exits straight away
+// with a status of 1.
+func (m *Manager) Wait(signals ...os.Signal) error {
+	// The channel holds two signals, so the second isn't dropped while the first is being logged.
+	quit := make(chan os.Signal, 2)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+
+	drain := true
+	select {
+	case s := <-quit:
+		m.logger.Info("shutting down", slog.String("signal", s.String()), slog.Duration("drain_delay", m.drainDelay), slog.Duration("timeout", m.timeout))
+	case reason := <-m.handover:
+		m.logger.Info("shutting down", slog.String("reason", reason), slog.Duration("timeout", m.timeout))
+		drain = false
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case s := <-quit:
+			m.logger.Error("forcing exit", slog.String("signal", s.String()))
+			m.exit(1)
+		case <-done:
+		}
+	}()
+
+	return m.shutdown(context.Background(), drain)
+}
+
+// Shutdown runs the drain hooks, waits for the drain delay, then runs the shutdown hooks.
+// Every hook is run even if an earlier one fails, and all of their errors are returned together.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	return m.shutdown(ctx, true)
+}
+
+// shutdown is Shutdown(), with the drain phase only being run if drain is true.
+func (m *Manager) shutdown(ctx context.Context, drain bool) error {
+	m.mu.Lock()
+	drainHooks := m.drainHooks
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	if drain {
+		for _, fn := range drainHooks {
+			fn()
+		}
+	}
+
+	if drain && m.drainDelay > 0 {
+		m.logger.Info("draining", slog.Duration("delay", m.drainDelay))
+		select {
+		case <-time.After(m.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+
+		start := time.Now()
+		err := h.fn(ctx)
+		if err != nil {
+			m.logger.Error("shutdown hook failed", slog.String("hook", h.name), slog.String("error", err.Error()))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		m.logger.Info("shutdown hook finished", slog.String("hook", h.name), slog.Duration("duration", time.Since(start)))
+	}
+	return errors.Join(errs...)
+}
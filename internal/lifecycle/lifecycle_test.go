@@ -0,0 +1,95 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func newTestManager(drainDelay, timeout time.Duration) *Manager {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), drainDelay, timeout)
+}
+
+func TestShutdownOrder(t *testing.T) {
+	m := newTestManager(0, time.Second)
+
+	var calls []string
+	m.OnDrain(func() { calls = append(calls, "drain") })
+	m.OnShutdown("database", func(ctx context.Context) error {
+		calls = append(calls, "database")
+		return nil
+	})
+	m.OnShutdown("jobs", func(ctx context.Context) error {
+		calls = append(calls, "jobs")
+		return errors.New("still running")
+	})
+	m.OnShutdown("server", func(ctx context.Context) error {
+		calls = append(calls, "server")
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+
+	// Hooks run in reverse order, and a failing hook doesn't stop the rest from running.
+	assert.Equal(t, len(calls), 4)
+	assert.Equal(t, calls[0], "drain")
+	assert.Equal(t, calls[1], "server")
+	assert.Equal(t, calls[2], "jobs")
+	assert.Equal(t, calls[3], "database")
+	assert.Equal(t, err.Error(), "jobs: still running")
+}
+
+func TestShutdownDrainDelay(t *testing.T) {
+	m := newTestManager(50*time.Millisecond, time.Second)
+
+	var drained time.Time
+	m.OnDrain(func() { drained = time.Now() })
+
+	var stopped time.Time
+	m.OnShutdown("server", func(ctx context.Context) error {
+		stopped = time.Now()
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, stopped.Sub(drained) >= 50*time.Millisecond, true)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	m := newTestManager(0, 10*time.Millisecond)
+
+	m.OnShutdown("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := m.Shutdown(context.Background())
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+}
+
+func TestWaitHandover(t *testing.T) {
+	m := newTestManager(time.Hour, time.Second)
+
+	drained := false
+	m.OnDrain(func() { drained = true })
+	stopped := false
+	m.OnShutdown("server", func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+
+	// Handing over skips draining, so this doesn't wait for the hour long drain delay.
+	m.Handover("restarted")
+	m.Handover("called twice")
+	err := m.Wait(syscall.SIGTERM)
+	assert.NilError(t, err)
+	assert.Equal(t, drained, false)
+	assert.Equal(t, stopped, true)
+}
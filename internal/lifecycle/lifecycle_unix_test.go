@@ -0,0 +1,64 @@
+//go:build unix
+
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestWaitForcedExit(t *testing.T) {
+	m := newTestManager(0, time.Second)
+
+	// Catch the signal in the test too, so it never kills the test binary, even before Wait() has registered for it.
+	caught := make(chan os.Signal, 10)
+	signal.Notify(caught, syscall.SIGUSR2)
+	defer signal.Stop(caught)
+
+	exited := make(chan int, 1)
+	m.exit = func(code int) { exited <- code }
+
+	// The hook blocks until the process is forced to exit, like one that's stuck.
+	release := make(chan struct{})
+	hookStarted := make(chan struct{})
+	m.OnShutdown("stuck", func(ctx context.Context) error {
+		close(hookStarted)
+		<-release
+		return nil
+	})
+
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- m.Wait(syscall.SIGUSR2)
+	}()
+
+	// Keep signalling until Wait() has registered for the signal and started shutting down.
+	for started := false; !started; {
+		err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+		assert.NilError(t, err)
+		select {
+		case <-hookStarted:
+			started = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	assert.NilError(t, err)
+
+	select {
+	case code := <-exited:
+		assert.Equal(t, code, 1)
+	case <-time.After(time.Second):
+		t.Fatal("second signal didn't force an exit")
+	}
+
+	close(release)
+	assert.NilError(t, <-waitErr)
+}
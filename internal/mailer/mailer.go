@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"text/template"
+)
+
+var errMailerNoTransport = errors.New("mailer has no transport configured")
+
+// Transport is anything that can deliver an already encoded email message.
+// SMTPTransport is used in production, and Recorder can be used in tests.
+type Transport interface {
+	Send(from string, to []string, msg []byte) error
+}
+
+// Mailer renders email templates and hands the results to a Transport for delivery.
+type Mailer struct {
+	transport Transport
+	sender    string
+	files     fs.FS
+	functions map[string]any
+}
+
+// New returns a new Mailer.
+// The templates are read from the "html/email" directory of files, and have access to the given template functions.
+// The sender is the name and address the emails are from, i.e. "Site <no-reply@example.com>".
+func New(transport Transport, sender string, files fs.FS, functions map[string]any) *Mailer {
+	return &Mailer{
+		transport: transport,
+		sender:    sender,
+		files:     files,
+		functions: functions,
+	}
+}
+
+// Send renders the "subject", "plainBody", and "htmlBody" templates from the templateFile and emails them to the recipient.
+// Sending is only attempted once, so it should be called from a background job, which retries it with a backoff if it fails.
+func (m *Mailer) Send(recipient, templateFile string, data any) error {
+	if m.transport == nil {
+		return errMailerNoTransport
+	}
+
+	msg, err := m.render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	from, err := msg.envelopeFrom()
+	if err != nil {
+		return err
+	}
+
+	to, err := msg.envelopeTo()
+	if err != nil {
+		return err
+	}
+
+	return m.transport.Send(from, to, encoded)
+}
+
+// render executes the templates inside the templateFile and returns the resulting Message.
+func (m *Mailer) render(recipient, templateFile string, data any) (*Message, error) {
+	filename := path.Join("html/email", templateFile)
+
+	// The subject and plain text body use text/template, so characters like "&" aren't HTML escaped.
+	tmpl, err := template.New(templateFile).Funcs(m.functions).ParseFS(m.files, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HTML body uses html/template, so any dynamic data is escaped properly.
+	htmlTmpl, err := htmltemplate.New(templateFile).Funcs(m.functions).ParseFS(m.files, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		From:      m.sender,
+		To:        recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}, nil
+}
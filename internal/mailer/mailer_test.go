@@ -0,0 +1,208 @@
+package mailer
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+// testFiles contains an email template shaped like the ones in ui/html/email.
+var testFiles = fstest.MapFS{
+	"html/email/test.tmpl": &fstest.MapFile{Data: []byte(`
+{{define "subject"}}Hello {{.name}} & friends{{end}}
+{{define "plainBody"}}Hi {{.name}}, this is {{upper "plain"}} text & such.{{end}}
+{{define "htmlBody"}}<p>Hi {{.name}}, this is {{upper "html"}}.</p>{{end}}
+`)},
+}
+
+// testFunctions mimics the template functions passed in from cmd/web.
+var testFunctions = map[string]any{
+	"upper": strings.ToUpper,
+}
+
+// parseEmail decodes the raw message and returns its subject and a map of content type to body.
+func parseEmail(t *testing.T, raw []byte) (string, map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// multipart.Reader transparently decodes quoted-printable parts.
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+
+	return subject, bodies
+}
+
+func TestMailerSend(t *testing.T) {
+	recorder := &Recorder{}
+	m := New(recorder, "Site <no-reply@example.com>", testFiles, testFunctions)
+
+	err := m.Send("Bob <bob@example.com>", "test.tmpl", map[string]any{"name": "<Bob>"})
+	assert.NilError(t, err)
+
+	emails := recorder.Emails()
+	assert.Equal(t, len(emails), 1)
+	assert.Equal(t, emails[0].From, "no-reply@example.com")
+	assert.Equal(t, len(emails[0].To), 1)
+	assert.Equal(t, emails[0].To[0], "bob@example.com")
+
+	subject, bodies := parseEmail(t, emails[0].Data)
+
+	// The subject and plain body shouldn't be HTML escaped, but the HTML body should be.
+	assert.Equal(t, subject, "Hello <Bob> & friends")
+	assert.Equal(t, bodies["text/plain"], "Hi <Bob>, this is PLAIN text & such.")
+	assert.Equal(t, bodies["text/html"], "<p>Hi &lt;Bob&gt;, this is HTML.</p>")
+}
+
+func TestMailerSendErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		transport    Transport
+		recipient    string
+		templateFile string
+	}{
+		{
+			name:         "No transport",
+			transport:    nil,
+			recipient:    "bob@example.com",
+			templateFile: "test.tmpl",
+		},
+		{
+			name:         "Missing template",
+			transport:    &Recorder{},
+			recipient:    "bob@example.com",
+			templateFile: "missing.tmpl",
+		},
+		{
+			name:         "Invalid recipient",
+			transport:    &Recorder{},
+			recipient:    "not an email",
+			templateFile: "test.tmpl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.transport, "no-reply@example.com", testFiles, testFunctions)
+			err := m.Send(tt.recipient, tt.templateFile, map[string]any{"name": "Bob"})
+			if err == nil {
+				t.Error("got: nil; want: an error")
+			}
+		})
+	}
+}
+
+// failingTransport is a Transport that counts how many times it's called, and always fails.
+type failingTransport struct {
+	calls int
+}
+
+func (t *failingTransport) Send(from string, to []string, msg []byte) error {
+	t.calls++
+	return errors.New("connection refused")
+}
+
+func TestMailerSendNoRetry(t *testing.T) {
+	// Retrying is left to the job queue, which backs off between attempts, so a failed send is only tried once.
+	transport := &failingTransport{}
+	m := New(transport, "no-reply@example.com", testFiles, testFunctions)
+
+	err := m.Send("bob@example.com", "test.tmpl", map[string]any{"name": "Bob"})
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, transport.calls, 1)
+}
+
+// newTestSMTPServer starts a minimal SMTP server that accepts a single message and sends its data on the returned channel.
+func newTestSMTPServer(t *testing.T) (string, int, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP test")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				_ = tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(line, "DATA"):
+				_ = tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				_ = tp.PrintfLine("250 ok")
+			case strings.HasPrefix(line, "QUIT"):
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestSMTPTransport(t *testing.T) {
+	host, port, received := newTestSMTPServer(t)
+
+	m := New(SMTPTransport{Host: host, Port: port}, "no-reply@example.com", testFiles, testFunctions)
+	err := m.Send("bob@example.com", "test.tmpl", map[string]any{"name": "Bob"})
+	assert.NilError(t, err)
+
+	data := <-received
+	subject, bodies := parseEmail(t, []byte(data))
+	assert.Equal(t, subject, "Hello Bob & friends")
+	assert.Equal(t, bodies["text/plain"], "Hi Bob, this is PLAIN text & such.")
+}
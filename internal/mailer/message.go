@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a rendered email that's ready to be encoded and sent.
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Bytes encodes the Message as a multipart/alternative MIME message, containing both the plain text and HTML bodies.
+func (msg *Message) Bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	id, err := messageID(msg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the headers first, followed by a blank line.
+	headers := []string{
+		"From: " + msg.From,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(msg.Subject)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + id,
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	// Email clients display the last part they support, so the HTML body goes last.
+	err = writePart(mw, "text/plain", msg.PlainBody)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writePart(mw, "text/html", msg.HTMLBody)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// envelopeFrom returns the bare email address of the sender for the SMTP envelope.
+func (msg *Message) envelopeFrom() (string, error) {
+	addr, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender %q: %w", msg.From, err)
+	}
+	return addr.Address, nil
+}
+
+// envelopeTo returns the bare email address of the recipient for the SMTP envelope.
+func (msg *Message) envelopeTo() ([]string, error) {
+	addr, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	return []string{addr.Address}, nil
+}
+
+// writePart adds a quoted-printable encoded part with the given content type to the multipart writer.
+func writePart(mw *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	pw, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qw := quotedprintable.NewWriter(pw)
+	_, err = qw.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// messageID generates a unique Message-ID header value using the sender's domain.
+func messageID(from string) (string, error) {
+	domain := "localhost"
+	addr, err := mail.ParseAddress(from)
+	if err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at != -1 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
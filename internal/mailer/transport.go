@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+)
+
+// SMTPTransport delivers emails to an SMTP server.
+// If a username is set, PLAIN authentication is used, which net/smtp only allows over TLS or to localhost.
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Send delivers the message to the SMTP server, upgrading the connection with STARTTLS when the server supports it.
+func (t SMTPTransport) Send(from string, to []string, msg []byte) error {
+	var auth smtp.Auth
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	}
+
+	addr := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	return smtp.SendMail(addr, auth, from, to, msg)
+}
+
+// RecordedEmail is a single email captured by a Recorder.
+type RecordedEmail struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Recorder is an in-memory Transport that keeps every email instead of sending it.
+// It's useful for tests, and for local development without an SMTP server.
+type Recorder struct {
+	mu     sync.Mutex
+	emails []RecordedEmail
+}
+
+// Send records the email.
+func (r *Recorder) Send(from string, to []string, msg []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.emails = append(r.emails, RecordedEmail{From: from, To: to, Data: msg})
+	return nil
+}
+
+// Emails returns a copy of every email recorded so far.
+func (r *Recorder) Emails() []RecordedEmail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	emails := make([]RecordedEmail, len(r.emails))
+	copy(emails, r.emails)
+	return emails
+}
@@ -0,0 +1,281 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// versionTable is the table used to track which migrations have been applied.
+// It's the same table goose uses, so databases that were migrated with goose carry on working.
+const versionTable = "goose_db_version"
+
+// lockID is the key for the Postgres advisory lock that stops multiple processes migrating at the same time.
+// It's an arbitrary number, it just needs to be the same for every instance of the site.
+const lockID = 8_105_733_251
+
+// ErrNoMigrationsApplied is returned by Down() when there's no applied migration to roll back.
+var ErrNoMigrationsApplied = errors.New("no migrations have been applied")
+
+// Status describes a single migration and whether it has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *pgxpool.Pool
+	logger     *slog.Logger
+	migrations []Migration
+}
+
+// New parses the migrations in fsys and returns a Migrator for the database.
+func New(db *pgxpool.Pool, logger *slog.Logger, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		logger:     logger,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies every migration that hasn't been applied yet, in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err = m.run(ctx, conn, migration, migration.Up, true)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+
+		m.logger.Info("migrations applied", slog.Int("count", count))
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Find the newest migration that has been applied.
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok {
+				return m.run(ctx, conn, migration, migration.Down, false)
+			}
+		}
+
+		return ErrNoMigrationsApplied
+	})
+}
+
+// Status returns every known migration, along with when it was applied, if it has been.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Version returns the version of the newest applied migration, or 0 if none have been applied.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int64
+	for v := range applied {
+		version = max(version, v)
+	}
+
+	return version, nil
+}
+
+// withLock runs fn while holding the migration advisory lock on a single connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Use a fresh context, so the lock is still released if ctx has been cancelled.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(unlockCtx, "SELECT pg_advisory_unlock($1)", lockID)
+	}()
+
+	return fn(conn)
+}
+
+// applied creates the version table if needed, and returns the applied migration versions mapped to when they were applied.
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	err := ensureVersionTable(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like goose, the newest row for each version decides whether it's applied.
+	rows, err := conn.Query(ctx, `SELECT version_id, is_applied, tstamp FROM `+versionTable+` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    time.Time
+		)
+		err = rows.Scan(&version, &isApplied, &tstamp)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+
+		// Version 0 is the marker row added when the table is created.
+		if isApplied && version > 0 {
+			applied[version] = tstamp
+		}
+	}
+
+	return applied, rows.Err()
+}
+
+// ensureVersionTable creates the version table if it doesn't exist yet, using the same layout goose does.
+func ensureVersionTable(ctx context.Context, conn *pgxpool.Conn) error {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, versionTable).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS `+versionTable+` (
+				id integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+				version_id bigint NOT NULL,
+				is_applied boolean NOT NULL,
+				tstamp timestamp NOT NULL DEFAULT now()
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO `+versionTable+` (version_id, is_applied) VALUES (0, true)`)
+		return err
+	})
+}
+
+// execer is implemented by both pgx.Tx and *pgxpool.Conn, so migrations can run with or without a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// run executes the statements for a migration and records the result in the version table.
+// Unless the migration opted out, the statements and the version change happen in a single transaction.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, migration Migration, statements []string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	apply := func(db execer) error {
+		for _, stmt := range statements {
+			_, err := db.Exec(ctx, stmt)
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if up {
+			_, err = db.Exec(ctx, `INSERT INTO `+versionTable+` (version_id, is_applied) VALUES ($1, true)`, migration.Version)
+		} else {
+			_, err = db.Exec(ctx, `DELETE FROM `+versionTable+` WHERE version_id = $1`, migration.Version)
+		}
+		return err
+	}
+
+	start := time.Now()
+	var err error
+	if migration.UseTx {
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			return apply(tx)
+		})
+	} else {
+		err = apply(conn)
+	}
+	if err != nil {
+		return fmt.Errorf("migrating %s %d_%s: %w", direction, migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("migrated", slog.String("direction", direction), slog.Int64("version", migration.Version),
+		slog.String("name", migration.Name), slog.Duration("duration", time.Since(start)))
+	return nil
+}
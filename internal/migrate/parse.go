@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMigrateBadFilename        = errors.New("migration filename must look like <version>_<name>.sql")
+	errMigrateDuplicateVersion   = errors.New("duplicate migration version")
+	errMigrateMissingUp          = errors.New("migration has no '-- +goose Up' annotation")
+	errMigrateUnclosedStatement  = errors.New("migration has a '-- +goose StatementBegin' without a matching '-- +goose StatementEnd'")
+	errMigrateStatementOutsideUp = errors.New("migration has SQL before the '-- +goose Up' annotation")
+)
+
+// Migration is a single parsed migration file.
+type Migration struct {
+	Version int64
+	Name    string
+	// Up and Down contain the individual SQL statements to run, in order.
+	Up   []string
+	Down []string
+	// UseTx is false if the file has a '-- +goose NO TRANSACTION' annotation, i.e. for CREATE INDEX CONCURRENTLY.
+	UseTx bool
+}
+
+// Parse reads every .sql file in the root of fsys and returns the migrations sorted by version.
+// The files use the same annotations as goose (https://github.com/pressly/goose), so they can still be managed with it.
+func Parse(fsys fs.FS) ([]Migration, error) {
+	filenames, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(filenames))
+	seen := make(map[int64]string, len(filenames))
+	for _, filename := range filenames {
+		version, name, err := parseFilename(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("%s and %s: %w", other, filename, errMigrateDuplicateVersion)
+		}
+		seen[version] = filename
+
+		contents, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := parseSQL(contents)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		m.Version = version
+		m.Name = name
+		migrations = append(migrations, m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// parseFilename splits a filename like "20240905232827_add_sessions_table.sql" into its version and name.
+func parseFilename(filename string) (int64, string, error) {
+	base := strings.TrimSuffix(path.Base(filename), ".sql")
+	v, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", errMigrateBadFilename
+	}
+
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version < 1 {
+		return 0, "", errMigrateBadFilename
+	}
+
+	return version, name, nil
+}
+
+// parseSQL splits the contents of a migration file into its up and down statements.
+// Statements end with a semicolon at the end of a line, unless they're wrapped in StatementBegin/StatementEnd annotations,
+// which is needed for things like functions that contain semicolons.
+func parseSQL(contents []byte) (Migration, error) {
+	m := Migration{UseTx: true}
+
+	var (
+		current     *[]string
+		buf         strings.Builder
+		inStatement bool
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			*current = append(*current, stmt)
+		}
+		buf.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Handle the goose annotations.
+		if annotation, ok := strings.CutPrefix(trimmed, "-- +goose "); ok {
+			switch strings.ToUpper(strings.TrimSpace(annotation)) {
+			case "UP":
+				current = &m.Up
+			case "DOWN":
+				if current != nil {
+					flush()
+				}
+				current = &m.Down
+			case "STATEMENTBEGIN":
+				inStatement = true
+			case "STATEMENTEND":
+				inStatement = false
+				flush()
+			case "NO TRANSACTION":
+				m.UseTx = false
+			}
+			continue
+		}
+
+		// Skip comments and blank lines outside of statements.
+		if !inStatement && buf.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		if current == nil {
+			return Migration{}, errMigrateStatementOutsideUp
+		}
+
+		buf.WriteString(line)
+		buf.WriteString("\n")
+
+		if !inStatement && strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Migration{}, err
+	}
+
+	if inStatement {
+		return Migration{}, errMigrateUnclosedStatement
+	}
+
+	if current == nil {
+		return Migration{}, errMigrateMissingUp
+	}
+
+	// Anything left over is a final statement without a trailing semicolon.
+	flush()
+
+	return m, nil
+}
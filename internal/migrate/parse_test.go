@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+	"github.com/rynhndrcksn/go-starter-site/migrations"
+)
+
+func TestParse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2_second.sql": &fstest.MapFile{Data: []byte(`-- +goose Up
+-- +goose NO TRANSACTION
+CREATE INDEX CONCURRENTLY IF NOT EXISTS things_name_idx ON things (name);
+
+-- +goose Down
+DROP INDEX IF EXISTS things_name_idx;
+`)},
+		"1_first.sql": &fstest.MapFile{Data: []byte(`-- A comment before the annotations is fine.
+-- +goose Up
+CREATE TABLE things (id BIGSERIAL PRIMARY KEY);
+-- This comment belongs to nothing.
+ALTER TABLE things
+    ADD COLUMN name TEXT;
+
+-- +goose StatementBegin
+CREATE FUNCTION one() RETURNS integer AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION one;
+DROP TABLE things;
+`)},
+		"readme.md": &fstest.MapFile{Data: []byte("not a migration")},
+	}
+
+	got, err := Parse(fsys)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got), 2)
+
+	// Migrations should be sorted by version.
+	assert.Equal(t, got[0].Version, int64(1))
+	assert.Equal(t, got[0].Name, "first")
+	assert.Equal(t, got[0].UseTx, true)
+	assert.Equal(t, slices.Equal(got[0].Up, []string{
+		"CREATE TABLE things (id BIGSERIAL PRIMARY KEY);",
+		"ALTER TABLE things\n    ADD COLUMN name TEXT;",
+		"CREATE FUNCTION one() RETURNS integer AS $$\nBEGIN\n    RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;",
+	}), true)
+	assert.Equal(t, slices.Equal(got[0].Down, []string{"DROP FUNCTION one;", "DROP TABLE things;"}), true)
+
+	assert.Equal(t, got[1].Version, int64(2))
+	assert.Equal(t, got[1].UseTx, false)
+	assert.Equal(t, len(got[1].Up), 1)
+	assert.Equal(t, len(got[1].Down), 1)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr error
+	}{
+		{
+			name:    "Bad filename",
+			files:   fstest.MapFS{"first.sql": &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 1;")}},
+			wantErr: errMigrateBadFilename,
+		},
+		{
+			name: "Duplicate version",
+			files: fstest.MapFS{
+				"1_first.sql":  &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 1;")},
+				"01_again.sql": &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 1;")},
+			},
+			wantErr: errMigrateDuplicateVersion,
+		},
+		{
+			name:    "Missing up annotation",
+			files:   fstest.MapFS{"1_first.sql": &fstest.MapFile{Data: []byte("-- just a comment\n")}},
+			wantErr: errMigrateMissingUp,
+		},
+		{
+			name:    "SQL before up annotation",
+			files:   fstest.MapFS{"1_first.sql": &fstest.MapFile{Data: []byte("SELECT 1;\n-- +goose Up\nSELECT 2;")}},
+			wantErr: errMigrateStatementOutsideUp,
+		},
+		{
+			name:    "Unclosed statement",
+			files:   fstest.MapFS{"1_first.sql": &fstest.MapFile{Data: []byte("-- +goose Up\n-- +goose StatementBegin\nSELECT 1;")}},
+			wantErr: errMigrateUnclosedStatement,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.files)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
+
+func TestParseEmbeddedMigrations(t *testing.T) {
+	// Make sure every migration shipped with the site can be parsed, and has both up and down statements.
+	got, err := Parse(migrations.Files)
+	assert.NilError(t, err)
+
+	if len(got) == 0 {
+		t.Fatal("no embedded migrations found")
+	}
+	for _, m := range got {
+		if len(m.Up) == 0 || len(m.Down) == 0 {
+			t.Errorf("migration %d_%s is missing up or down statements", m.Version, m.Name)
+		}
+	}
+}
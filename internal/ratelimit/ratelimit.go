@@ -0,0 +1,158 @@
+// Package ratelimit implements an in memory token bucket rate limiter, with a bucket per key (such as a client IP).
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have been idle long enough to refill are removed.
+// A full bucket behaves exactly like a missing one, so removing them doesn't change anything other than memory usage.
+const sweepInterval = time.Minute
+
+// Limiter hands out tokens from a bucket per key.
+// Each bucket holds up to Burst tokens, and refills at Rate tokens per second.
+// Idle buckets are swept in the background until Stop() is called.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// now can be replaced in tests.
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result describes the outcome of a call to Allow().
+type Result struct {
+	// Allowed is true if a token was taken from the bucket.
+	Allowed bool
+	// Limit is the size of the bucket.
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// RetryAfter is how long until the next token is available, which is zero if there's one available now.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// New returns a Limiter that allows bursts of up to burst requests, refilling at rate requests per second.
+// It starts a goroutine to sweep idle buckets, which runs until Stop() is called.
+func New(rate float64, burst int) *Limiter {
+	l := newLimiter(rate, burst, time.Now)
+	go l.sweepEvery(sweepInterval)
+	return l
+}
+
+// newLimiter returns a Limiter without starting the sweep goroutine, so tests can control the clock and when sweeps happen.
+func newLimiter(rate float64, burst int, now func() time.Time) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		stop:    make(chan struct{}),
+		now:     now,
+	}
+}
+
+// Stop stops sweeping idle buckets. It's safe to call more than once, and the Limiter can still be used afterwards.
+func (l *Limiter) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+// Allow takes a token from the bucket for key, if there's one available.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now, l.rate, l.burst)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return Result{
+		Allowed:    allowed,
+		Limit:      l.burst,
+		Remaining:  int(b.tokens),
+		RetryAfter: l.wait(1 - b.tokens),
+		Reset:      l.wait(float64(l.burst) - b.tokens),
+	}
+}
+
+// Len returns the number of buckets currently being tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweepEvery calls sweep() every interval, until Stop() is called.
+func (l *Limiter) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.sweep()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// sweep removes every bucket that would be full by now.
+func (l *Limiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		b.refill(now, l.rate, l.burst)
+		if b.tokens >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// wait returns how long it takes to refill the given number of tokens.
+func (l *Limiter) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if l.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// refill adds the tokens earned since the bucket was last used, up to burst.
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = min(float64(burst), b.tokens+elapsed*rate)
+		b.last = now
+	}
+}
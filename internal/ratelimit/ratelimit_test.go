@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+// newTestLimiter returns a Limiter with a clock that only moves when advance is called.
+func newTestLimiter(rate float64, burst int) (*Limiter, func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLimiter(rate, burst, func() time.Time { return now })
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAllow(t *testing.T) {
+	l, advance := newTestLimiter(1, 3)
+
+	// The bucket starts full, so the whole burst is allowed straight away.
+	for i := range 3 {
+		res := l.Allow("a")
+		assert.Equal(t, res.Allowed, true)
+		assert.Equal(t, res.Limit, 3)
+		assert.Equal(t, res.Remaining, 2-i)
+	}
+
+	res := l.Allow("a")
+	assert.Equal(t, res.Allowed, false)
+	assert.Equal(t, res.Remaining, 0)
+	assert.Equal(t, res.RetryAfter, time.Second)
+	assert.Equal(t, res.Reset, 3*time.Second)
+
+	// Other keys have their own bucket.
+	assert.Equal(t, l.Allow("b").Allowed, true)
+
+	// Tokens refill at the configured rate.
+	advance(time.Second)
+	res = l.Allow("a")
+	assert.Equal(t, res.Allowed, true)
+	assert.Equal(t, res.RetryAfter, time.Second)
+	assert.Equal(t, l.Allow("a").Allowed, false)
+
+	// But never past the burst.
+	advance(time.Hour)
+	res = l.Allow("a")
+	assert.Equal(t, res.Remaining, 2)
+}
+
+func TestSweep(t *testing.T) {
+	l, advance := newTestLimiter(1, 2)
+
+	l.Allow("idle")
+	l.Allow("busy")
+	assert.Equal(t, l.Len(), 2)
+
+	// Buckets that have refilled by the time of a sweep are removed.
+	advance(time.Second)
+	l.Allow("busy")
+	l.sweep()
+	assert.Equal(t, l.Len(), 1)
+
+	// Exhausted buckets are kept, so they can't be reset by waiting for a sweep.
+	l, advance = newTestLimiter(0.001, 1)
+	l.Allow("slow")
+	advance(sweepInterval)
+	l.sweep()
+	assert.Equal(t, l.Len(), 1)
+	assert.Equal(t, l.Allow("slow").Allowed, false)
+}
+
+func TestSweepEvery(t *testing.T) {
+	l := newLimiter(1000, 1, time.Now)
+	l.Allow("a")
+	assert.Equal(t, l.Len(), 1)
+
+	done := make(chan struct{})
+	go func() {
+		l.sweepEvery(time.Millisecond)
+		close(done)
+	}()
+
+	// The bucket refills within a few milliseconds, so the background sweep should remove it.
+	deadline := time.Now().Add(time.Second)
+	for l.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, l.Len(), 0)
+
+	// Stop ends the goroutine, and can be called again safely.
+	l.Stop()
+	l.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweepEvery didn't return after Stop")
+	}
+}
@@ -0,0 +1,147 @@
+// Package realip works out the IP address of the client that made a request, when the site is behind one or more proxies.
+//
+// Proxy headers are trivially spoofed, so they're only believed when the request comes from a trusted proxy.
+// Addresses are then read from right to left, skipping any trusted proxies, so the first untrusted address is used.
+// Anything to the left of that could have been made up by the client, so it's ignored.
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver resolves client IP addresses. The zero value trusts no proxies, and always uses the connection's address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New returns a Resolver that trusts proxy headers sent by the given networks.
+func New(trusted []netip.Prefix) Resolver {
+	return Resolver{trusted: trusted}
+}
+
+// ParsePrefixes parses a list of CIDRs, such as "10.0.0.0/8", or single IP addresses, such as "192.0.2.1".
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("realip: invalid trusted proxy %q", value)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("realip: invalid trusted proxy %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ClientIP returns the IP address of the client that made r.
+// If the connection's address can't be parsed (which shouldn't happen with net/http), the invalid zero netip.Addr is returned.
+func (res Resolver) ClientIP(r *http.Request) netip.Addr {
+	peer := parseAddr(r.RemoteAddr)
+	if !peer.IsValid() || !res.isTrusted(peer) {
+		return peer
+	}
+
+	// The closest proxy is trusted, so look at what it says, preferring the standardised header.
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		return res.walk(peer, forwardedFor(values))
+	}
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		return res.walk(peer, splitList(values))
+	}
+	if value := r.Header.Get("X-Real-IP"); value != "" {
+		addr := parseAddr(value)
+		if addr.IsValid() {
+			return addr
+		}
+	}
+	return peer
+}
+
+// walk goes through hops from right to left, returning the first address that isn't a trusted proxy.
+// If a hop can't be parsed, nothing before it can be relied on, so the last valid address is returned instead.
+func (res Resolver) walk(peer netip.Addr, hops []string) netip.Addr {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr := parseAddr(hops[i])
+		if !addr.IsValid() {
+			return client
+		}
+
+		client = addr
+		if !res.isTrusted(addr) {
+			return client
+		}
+	}
+	return client
+}
+
+func (res Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits comma separated header values, such as X-Forwarded-For, into their items.
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for item := range strings.SplitSeq(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// forwardedFor returns the for= parameter of each element in RFC 7239 Forwarded headers, i.e.
+//
+//	Forwarded: for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"
+//
+// Elements without a for= parameter are returned as empty strings, so they're treated as unparseable.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		var hop string
+		for pair := range strings.SplitSeq(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// parseAddr parses an IP address that may have a port, and may be wrapped in square brackets if it's IPv6.
+// Obfuscated identifiers and "unknown" from the Forwarded header return the invalid zero netip.Addr.
+func parseAddr(value string) netip.Addr {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,129 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParsePrefixes([]string{"10.0.0.0/8", "192.0.2.1", "fd00::/8"})
+	assert.NilError(t, err)
+	res := New(trusted)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{
+			name:       "Direct connection",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Untrusted peer is ignored",
+			remoteAddr: "203.0.113.7:51234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For skips trusted proxies",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1, 192.0.2.1", "10.1.1.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For ignores spoofed addresses",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For garbage",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Forwarded-For": {"1.1.1.1, nonsense, 10.2.2.2"}},
+			want:       "10.2.2.2",
+		},
+		{
+			name:       "X-Forwarded-For all trusted",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Forwarded-For": {"10.3.3.3"}},
+			want:       "10.3.3.3",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Forwarded",
+			remoteAddr: "[fd00::1]:443",
+			header:     http.Header{"Forwarded": {`for=1.1.1.1, for="[2001:db8:cafe::17]:4711";proto=https`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded is preferred",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"Forwarded": {"For=198.51.100.2"}, "X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.2",
+		},
+		{
+			name:       "Forwarded unknown",
+			remoteAddr: "10.0.0.1:51234",
+			header:     http.Header{"Forwarded": {"for=unknown"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "IPv4 mapped peer",
+			remoteAddr: "[::ffff:10.0.0.1]:51234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+
+			assert.Equal(t, res.ClientIP(r).String(), tt.want)
+		})
+	}
+}
+
+func TestZeroResolver(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:51234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	var res Resolver
+	assert.Equal(t, res.ClientIP(r).String(), "10.0.0.1")
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.1.2.3/8", " 192.0.2.1 ", "::1"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(prefixes), 3)
+	assert.Equal(t, prefixes[0].String(), "10.0.0.0/8")
+	assert.Equal(t, prefixes[1].String(), "192.0.2.1/32")
+	assert.Equal(t, prefixes[2].String(), "::1/128")
+
+	_, err = ParsePrefixes([]string{"10.0.0.0/33"})
+	assert.Equal(t, err != nil, true)
+	_, err = ParsePrefixes([]string{"proxy.example.com"})
+	assert.Equal(t, err != nil, true)
+}
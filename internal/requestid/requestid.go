@@ -0,0 +1,76 @@
+// Package requestid ties everything that happens during a request together with a unique ID.
+// The ID is carried in the request context, and added to every log record made with that context by Handler.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+)
+
+// Header is the HTTP header used to accept an ID from an upstream proxy, and to send it back to the client.
+const Header = "X-Request-ID"
+
+// maxLength is the longest incoming ID that's accepted, anything longer is replaced.
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a new random ID.
+func New() string {
+	return rand.Text()
+}
+
+// Valid reports whether id is safe to accept from a client, and put in logs and headers.
+// Only letters, digits, and a few punctuation characters are allowed, which rules out log injection.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID carried by ctx, if there is one.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok
+}
+
+// Handler is a slog.Handler that adds a "request_id" attribute to every record logged with a context carrying an ID.
+// This only works for the *Context logging methods, such as logger.InfoContext(r.Context(), ...).
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps h, so request IDs are added to its records.
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := FromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
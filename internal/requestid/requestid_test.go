@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: New(), want: true},
+		{id: "f47ac10b-58cc-4372-a567-0e02b2c3d479", want: true},
+		{id: "Root=1-67891233-abcdef012345678912345678", want: false},
+		{id: "", want: false},
+		{id: strings.Repeat("a", maxLength+1), want: false},
+		{id: "abc\ninjected=true", want: false},
+		{id: "abc def", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, Valid(tt.id), tt.want)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&buf, nil)))
+
+	ctx := NewContext(context.Background(), "abc123")
+	logger.With("component", "test").InfoContext(ctx, "with id")
+	assert.StringContains(t, buf.String(), "component=test request_id=abc123")
+
+	// Records logged without a context, or with one that doesn't carry an ID, are left alone.
+	buf.Reset()
+	logger.Info("without id")
+	assert.Equal(t, strings.Contains(buf.String(), "request_id"), false)
+
+	id, ok := FromContext(ctx)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, id, "abc123")
+}
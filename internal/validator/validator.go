@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// EmailRX is a regular expression for sanity checking the format of email addresses.
+// This pattern is the one recommended by the W3C and Web Hypertext Application Technology Working Group.
+// More information can be found here: https://html.spec.whatwg.org/multipage/input.html#valid-e-mail-address
+var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+// Validator contains a map of validation errors for form fields, and a slice of errors not related to a specific field.
+// Embed it in a form struct to give the form the validation helpers below.
+type Validator struct {
+	FieldErrors    map[string]string
+	NonFieldErrors []string
+}
+
+// Valid returns true if the Validator doesn't contain any errors.
+func (v *Validator) Valid() bool {
+	return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
+}
+
+// AddFieldError adds an error message to the FieldErrors map, as long as no entry already exists for the given key.
+func (v *Validator) AddFieldError(key, message string) {
+	// Initialize the map first, if it isn't already initialized.
+	if v.FieldErrors == nil {
+		v.FieldErrors = make(map[string]string)
+	}
+
+	if _, exists := v.FieldErrors[key]; !exists {
+		v.FieldErrors[key] = message
+	}
+}
+
+// AddNonFieldError adds an error message to the NonFieldErrors slice.
+func (v *Validator) AddNonFieldError(message string) {
+	v.NonFieldErrors = append(v.NonFieldErrors, message)
+}
+
+// CheckField adds an error message to the FieldErrors map only if the validation check is not ok.
+func (v *Validator) CheckField(ok bool, key, message string) {
+	if !ok {
+		v.AddFieldError(key, message)
+	}
+}
+
+// NotBlank returns true if a value is not an empty string.
+func NotBlank(value string) bool {
+	return strings.TrimSpace(value) != ""
+}
+
+// MaxChars returns true if a value contains no more than n characters.
+func MaxChars(value string, n int) bool {
+	return utf8.RuneCountInString(value) <= n
+}
+
+// MinChars returns true if a value contains at least n characters.
+func MinChars(value string, n int) bool {
+	return utf8.RuneCountInString(value) >= n
+}
+
+// MaxBytes returns true if a value contains no more than n bytes.
+func MaxBytes(value string, n int) bool {
+	return len(value) <= n
+}
+
+// Matches returns true if a value matches the provided compiled regular expression pattern.
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
+// PermittedValue returns true if a value is in a list of specific permitted values.
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	return slices.Contains(permittedValues, value)
+}
@@ -0,0 +1,133 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rynhndrcksn/go-starter-site/internal/assert"
+)
+
+func TestValidator(t *testing.T) {
+	v := Validator{}
+	assert.Equal(t, v.Valid(), true)
+
+	// Only the first error for a field should be kept.
+	v.CheckField(false, "email", "first")
+	v.CheckField(false, "email", "second")
+	v.CheckField(true, "password", "never added")
+	assert.Equal(t, v.Valid(), false)
+	assert.Equal(t, len(v.FieldErrors), 1)
+	assert.Equal(t, v.FieldErrors["email"], "first")
+
+	v = Validator{}
+	v.AddNonFieldError("something went wrong")
+	assert.Equal(t, v.Valid(), false)
+}
+
+func TestNotBlank(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{
+			name:  "empty string",
+			input: "",
+			want:  false,
+		},
+		{
+			name:  "only whitespace",
+			input: " \t\n",
+			want:  false,
+		},
+		{
+			name:  "has content",
+			input: "content",
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, NotBlank(tt.input), tt.want)
+		})
+	}
+}
+
+func TestCharCounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		n       int
+		wantMin bool
+		wantMax bool
+	}{
+		{
+			name:    "shorter than n",
+			input:   "abc",
+			n:       4,
+			wantMin: false,
+			wantMax: true,
+		},
+		{
+			name:    "exactly n",
+			input:   "abcd",
+			n:       4,
+			wantMin: true,
+			wantMax: true,
+		},
+		{
+			name:    "multibyte characters count once",
+			input:   "ééé",
+			n:       3,
+			wantMin: true,
+			wantMax: true,
+		},
+		{
+			name:    "longer than n",
+			input:   strings.Repeat("a", 5),
+			n:       4,
+			wantMin: true,
+			wantMax: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, MinChars(tt.input, tt.n), tt.wantMin)
+			assert.Equal(t, MaxChars(tt.input, tt.n), tt.wantMax)
+		})
+	}
+}
+
+func TestMatchesEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{
+			name:  "valid email",
+			input: "alice@example.com",
+			want:  true,
+		},
+		{
+			name:  "missing at sign",
+			input: "alice.example.com",
+			want:  false,
+		},
+		{
+			name:  "missing domain",
+			input: "alice@",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Matches(tt.input, EmailRX), tt.want)
+		})
+	}
+}
+
+func TestPermittedValue(t *testing.T) {
+	assert.Equal(t, PermittedValue("b", "a", "b", "c"), true)
+	assert.Equal(t, PermittedValue(4, 1, 2, 3), false)
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tokens
+(
+    hash    BYTEA PRIMARY KEY,
+    user_id BIGINT      NOT NULL REFERENCES users ON DELETE CASCADE,
+    expiry  TIMESTAMPTZ NOT NULL,
+    scope   TEXT        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tokens_user_id_idx ON tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tokens;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS verified_at          TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS verification_sent_at;
+-- +goose StatementEnd
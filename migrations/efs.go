@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// Files contains all the SQL migrations in this directory, so the web binary can apply them itself.
+//
+//go:embed "*.sql"
+var Files embed.FS
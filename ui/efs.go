@@ -2,7 +2,7 @@ package ui
 
 import "embed"
 
-// Files contains all the contents of the ui/static/ directory
+// Files contains all the contents of the ui/html/ and ui/static/ directories
 // because of the go:embed "comment directive".
 // This also supports multiple paths: //go:embed "static/css" "static/img" "static/js".
 // This also supports specific files: //go:embed "static/css/main.css" "static/img" "static/js"